@@ -0,0 +1,205 @@
+package sugar
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+var (
+	contextType      = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType        = reflect.TypeOf((*error)(nil)).Elem()
+	httpResponseType = reflect.TypeOf((*http.Response)(nil))
+)
+
+// Bind implements func-typed fields of the struct pointed by v via client.Do.
+// Each field declares its endpoint by a sugar tag, e.g. `sugar:"GET /books/:id"`,
+// and the url is joined with baseUrl.
+//
+// Arguments are passed to encoders as params. An optional params tag maps arguments onto
+// Path{}, Query{}, Header{}, Cookie{}, Form{}, Json{} or Xml{} params by position,
+// e.g. `params:"path:id,query:page,json"`. An empty entry keeps the argument as it is.
+// A leading context.Context argument is used as the request context.
+//
+// A field must return an error as its last result, optionally preceded by a value
+// which is decoded via decoders and a *http.Response.
+func (c *Client) Bind(baseUrl string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("sugar: Bind requires a pointer to a struct, got %T", v)
+	}
+
+	rv = rv.Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		endpoint, ok := field.Tag.Lookup("sugar")
+		if !ok {
+			continue
+		}
+
+		if field.Type.Kind() != reflect.Func || !rv.Field(i).CanSet() {
+			return fmt.Errorf("sugar: field %s must be an exported func", field.Name)
+		}
+
+		fn, err := c.bindFunc(baseUrl, field, endpoint)
+		if err != nil {
+			return err
+		}
+		rv.Field(i).Set(fn)
+	}
+	return nil
+}
+
+// Bind binds endpoints via the default client.
+func Bind(baseUrl string, v interface{}) error {
+	return defaultClient.Bind(baseUrl, v)
+}
+
+func (c *Client) bindFunc(baseUrl string, field reflect.StructField, endpoint string) (reflect.Value, error) {
+	parts := strings.Fields(endpoint)
+	if len(parts) != 2 {
+		return reflect.Value{}, fmt.Errorf("sugar: field %s has an invalid endpoint %q", field.Name, endpoint)
+	}
+	method, rawUrl := strings.ToUpper(parts[0]), strings.TrimRight(baseUrl, "/")+parts[1]
+
+	t := field.Type
+	numOut := t.NumOut()
+	if numOut == 0 || numOut > 3 || t.Out(numOut-1) != errorType {
+		return reflect.Value{}, fmt.Errorf("sugar: field %s must return an error as its last result", field.Name)
+	}
+	if numOut == 3 && t.Out(1) != httpResponseType {
+		return reflect.Value{}, fmt.Errorf("sugar: field %s must return *http.Response as its second result", field.Name)
+	}
+
+	hasContext := t.NumIn() > 0 && t.In(0) == contextType
+	argc := t.NumIn()
+	if hasContext {
+		argc--
+	}
+	mappers, err := parseParamsTag(field.Tag.Get("params"), argc)
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("sugar: field %s: %v", field.Name, err)
+	}
+
+	return reflect.MakeFunc(t, func(args []reflect.Value) []reflect.Value {
+		ctx := context.Background()
+		if hasContext {
+			if c, ok := args[0].Interface().(context.Context); ok && c != nil {
+				ctx = c
+			}
+			args = args[1:]
+		}
+
+		var params []interface{}
+		for i, arg := range args {
+			if t.IsVariadic() && i == len(args)-1 {
+				for j := 0; j < arg.Len(); j++ {
+					params = append(params, arg.Index(j).Interface())
+				}
+				continue
+			}
+			params = append(params, mappers[i](arg.Interface()))
+		}
+
+		return bindResults(t, c.Do(ctx, method, rawUrl, mergePaths(params)...))
+	}), nil
+}
+
+func bindResults(t reflect.Type, r *Response) []reflect.Value {
+	numOut := t.NumOut()
+	results := make([]reflect.Value, numOut)
+	for i := range results {
+		results[i] = reflect.Zero(t.Out(i))
+	}
+
+	var resp *http.Response
+	var err error
+	switch {
+	case numOut == 1:
+		defer r.Close()
+		resp, err = r.Raw()
+	case t.Out(0) == httpResponseType:
+		resp, err = r.Raw()
+		results[0] = reflect.ValueOf(resp)
+	default:
+		out := reflect.New(t.Out(0))
+		resp, err = r.Read(out.Interface())
+		results[0] = out.Elem()
+	}
+
+	if numOut == 3 && resp != nil {
+		results[1] = reflect.ValueOf(resp)
+	}
+	if err != nil {
+		results[numOut-1] = reflect.ValueOf(&err).Elem()
+	}
+	return results
+}
+
+// mergePaths merges Path{} params into one, because PathEncoder replaces every path variable
+// and a variable missing from the current Path{} would be replaced with an empty string.
+func mergePaths(params []interface{}) []interface{} {
+	var path Path
+	merged := make([]interface{}, 0, len(params))
+	for _, param := range params {
+		p, ok := param.(Path)
+		if !ok {
+			merged = append(merged, param)
+			continue
+		}
+		if path == nil {
+			path = Path{}
+			merged = append(merged, path)
+		}
+		for k, v := range p {
+			path[k] = v
+		}
+	}
+	return merged
+}
+
+func parseParamsTag(tag string, argc int) ([]func(interface{}) interface{}, error) {
+	mappers := make([]func(interface{}) interface{}, argc)
+	for i := range mappers {
+		mappers[i] = func(v interface{}) interface{} { return v }
+	}
+
+	if tag == "" {
+		return mappers, nil
+	}
+
+	entries := strings.Split(tag, ",")
+	if len(entries) > argc {
+		return nil, fmt.Errorf("params tag has %d entries but only %d arguments", len(entries), argc)
+	}
+
+	for i, entry := range entries {
+		kind, name := strings.TrimSpace(entry), ""
+		if j := strings.Index(kind, ":"); j >= 0 {
+			kind, name = kind[:j], kind[j+1:]
+		}
+
+		switch kind {
+		case "":
+		case "path":
+			mappers[i] = func(v interface{}) interface{} { return Path{name: v} }
+		case "query":
+			mappers[i] = func(v interface{}) interface{} { return Query{name: v} }
+		case "header":
+			mappers[i] = func(v interface{}) interface{} { return Header{name: v} }
+		case "cookie":
+			mappers[i] = func(v interface{}) interface{} { return Cookie{name: v} }
+		case "form":
+			mappers[i] = func(v interface{}) interface{} { return Form{name: v} }
+		case "json":
+			mappers[i] = func(v interface{}) interface{} { return Json{v} }
+		case "xml":
+			mappers[i] = func(v interface{}) interface{} { return Xml{v} }
+		default:
+			return nil, fmt.Errorf("unknown param kind %q", kind)
+		}
+	}
+	return mappers, nil
+}
@@ -0,0 +1,89 @@
+package sugar
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+type bookApi struct {
+	GetBook    func(ctx context.Context, id int, page int) (book, error)       `sugar:"GET /books/:id" params:"path:id,query:page"`
+	CreateBook func(ctx context.Context, b book) (book, *http.Response, error) `sugar:"POST /books" params:"json"`
+	DeleteBook func(params ...interface{}) error                               `sugar:"DELETE /books/:id"`
+}
+
+func TestClient_Bind(t *testing.T) {
+	defer gock.Off()
+	gock.New("http://api.example.com").
+		Get("/books/1").
+		MatchParam("page", "2").
+		Reply(http.StatusOK).
+		JSON(`{"name":"bookA"}`)
+	gock.New("http://api.example.com").
+		Post("/books").
+		JSON(`{"Name":"bookB"}`).
+		Reply(http.StatusCreated).
+		JSON(`{"name":"bookB"}`)
+	gock.New("http://api.example.com").
+		Delete("/books/1").
+		Reply(http.StatusNoContent)
+
+	var api bookApi
+	err := New(StandardClient).Bind("http://api.example.com/", &api)
+	assert.Nil(t, err)
+
+	b, err := api.GetBook(context.Background(), 1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, "bookA", b.Name)
+
+	b, resp, err := api.CreateBook(context.Background(), book{Name: "bookB"})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "bookB", b.Name)
+
+	err = api.DeleteBook(Path{"id": 1})
+	assert.Nil(t, err)
+}
+
+func TestClient_Bind_Merges_Path_Params(t *testing.T) {
+	var req *http.Request
+	client := New(func() Transporter {
+		return HandlerTransporter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req = r
+		}))
+	})
+
+	var api struct {
+		GetAuthor func(bid, aid int) error `sugar:"GET /books/:bid/authors/:aid" params:"path:bid,path:aid"`
+	}
+	assert.Nil(t, client.Bind("http://api.example.com", &api))
+
+	assert.Nil(t, api.GetAuthor(1, 2))
+	assert.Equal(t, "/books/1/authors/2", req.URL.Path)
+}
+
+func TestClient_Bind_Returns_Error_If_Request_Fails(t *testing.T) {
+	var api struct {
+		Get func() (book, error) `sugar:"GET :://bad"`
+	}
+	assert.Nil(t, Bind("", &api))
+
+	_, err := api.Get()
+	assert.NotNil(t, err)
+}
+
+func TestClient_Bind_Returns_Error_If_Field_Is_Invalid(t *testing.T) {
+	assert.NotNil(t, Bind("", struct{}{}))
+	assert.NotNil(t, Bind("", &struct {
+		Get func() book `sugar:"GET /books"`
+	}{}))
+	assert.NotNil(t, Bind("", &struct {
+		Get func() error `sugar:"/books"`
+	}{}))
+	assert.NotNil(t, Bind("", &struct {
+		Get func(id int) error `sugar:"GET /books/:id" params:"body:id"`
+	}{}))
+}
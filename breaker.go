@@ -0,0 +1,304 @@
+package sugar
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a circuit.
+type CircuitState int
+
+const (
+	// StateClosed lets requests through and counts failures.
+	StateClosed CircuitState = iota
+	// StateOpen rejects requests with ErrCircuitOpen.
+	StateOpen
+	// StateHalfOpen lets a limited number of probe requests through.
+	StateHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+const windowBuckets = 10
+
+// CircuitBreaker is a plugin which stops sending requests to a failing downstream for a while.
+// Circuits are isolated by keys, and it must be used as a pointer, e.g. UsePlugin(&CircuitBreaker{...}).
+type CircuitBreaker struct {
+	// ConsecutiveFailures opens the circuit after the number of consecutive failures if it is positive.
+	ConsecutiveFailures int
+	// FailureRatio opens the circuit when the ratio of failures in the rolling window reaches it if it is positive.
+	FailureRatio float64
+	// MinRequests is the minimum number of requests in the window before FailureRatio is checked.
+	MinRequests int
+	// Window is the length of the rolling window, default 1 minute.
+	Window time.Duration
+	// OpenTimeout is how long the circuit stays open before a probe is allowed, default 30 seconds.
+	OpenTimeout time.Duration
+	// HalfOpenRequests is the maximum number of concurrent probes in half-open state, default 1.
+	HalfOpenRequests int
+	// IsFailure classifies the result of a request. DefaultIsFailure is used if it is nil.
+	// Canceled requests are neither failures nor successes, so they are never classified.
+	IsFailure func(c *Context, err error) bool
+	// Key isolates circuits. HostKey is used if it is nil.
+	Key func(c *Context) string
+	// OnStateChange is called when the state of a circuit changes. It is called without holding the lock of the breaker.
+	OnStateChange func(key string, from, to CircuitState)
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+	now      func() time.Time
+	// transitions are made under mu and reported by unlock.
+	transitions []transition
+}
+
+// DefaultIsFailure treats errors and 5xx responses as failures.
+func DefaultIsFailure(c *Context, err error) bool {
+	if err != nil {
+		return true
+	}
+	return c.Response != nil && c.Response.StatusCode >= http.StatusInternalServerError
+}
+
+// HostKey isolates circuits by host.
+func HostKey(c *Context) string {
+	if c.Request != nil {
+		return c.Request.URL.Host
+	}
+	return ""
+}
+
+// RouteKey isolates circuits by method and the url template before path params are encoded, e.g. "GET api.example.com/books/:id".
+func RouteKey(c *Context) string {
+	u, err := url.Parse(c.RawUrl)
+	if err != nil {
+		return c.Method + " " + c.RawUrl
+	}
+	return c.Method + " " + u.Host + u.Path
+}
+
+// State returns the state of the circuit for the key.
+func (b *CircuitBreaker) State(key string) CircuitState {
+	b.mu.Lock()
+	defer b.unlock()
+
+	if cb, ok := b.circuits[key]; ok {
+		b.refresh(key, cb)
+		return cb.state
+	}
+	return StateClosed
+}
+
+// Handle rejects the request with ErrCircuitOpen if the circuit is open, otherwise records the result of the request.
+func (b *CircuitBreaker) Handle(c *Context) error {
+	key := b.key(c)
+	generation, err := b.allow(key)
+	if err != nil {
+		return err
+	}
+
+	err = c.Next()
+	if errors.Is(err, context.Canceled) {
+		b.release(key, generation)
+		return err
+	}
+
+	isFailure := b.IsFailure
+	if isFailure == nil {
+		isFailure = DefaultIsFailure
+	}
+	b.record(key, generation, isFailure(c, err))
+	return err
+}
+
+type bucket struct {
+	start    time.Time
+	total    int
+	failures int
+}
+
+type circuit struct {
+	state       CircuitState
+	generation  int
+	consecutive int
+	openedAt    time.Time
+	probes      int
+	buckets     [windowBuckets]bucket
+}
+
+func (b *CircuitBreaker) key(c *Context) string {
+	if b.Key != nil {
+		return b.Key(c)
+	}
+	return HostKey(c)
+}
+
+func (b *CircuitBreaker) clock() time.Time {
+	if b.now != nil {
+		return b.now()
+	}
+	return time.Now()
+}
+
+func (b *CircuitBreaker) window() time.Duration {
+	if b.Window > 0 {
+		return b.Window
+	}
+	return time.Minute
+}
+
+func (b *CircuitBreaker) allow(key string) (int, error) {
+	b.mu.Lock()
+	defer b.unlock()
+
+	if b.circuits == nil {
+		b.circuits = map[string]*circuit{}
+	}
+	cb, ok := b.circuits[key]
+	if !ok {
+		cb = &circuit{}
+		b.circuits[key] = cb
+	}
+
+	b.refresh(key, cb)
+	switch cb.state {
+	case StateOpen:
+		return 0, ErrCircuitOpen
+	case StateHalfOpen:
+		limit := b.HalfOpenRequests
+		if limit <= 0 {
+			limit = 1
+		}
+		if cb.probes >= limit {
+			return 0, ErrCircuitOpen
+		}
+		cb.probes++
+	}
+	return cb.generation, nil
+}
+
+// refresh turns an open circuit into half-open once OpenTimeout elapses.
+func (b *CircuitBreaker) refresh(key string, cb *circuit) {
+	timeout := b.OpenTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	if cb.state == StateOpen && b.clock().Sub(cb.openedAt) >= timeout {
+		b.transit(key, cb, StateHalfOpen)
+	}
+}
+
+func (b *CircuitBreaker) record(key string, generation int, failure bool) {
+	b.mu.Lock()
+	defer b.unlock()
+
+	cb := b.circuits[key]
+	if cb.generation != generation {
+		return
+	}
+
+	switch cb.state {
+	case StateHalfOpen:
+		cb.probes--
+		if failure {
+			b.transit(key, cb, StateOpen)
+		} else {
+			b.transit(key, cb, StateClosed)
+		}
+	case StateClosed:
+		now := b.clock()
+		span := b.window() / windowBuckets
+		if span <= 0 {
+			span = 1
+		}
+		current := &cb.buckets[(now.UnixNano()/int64(span))%windowBuckets]
+		if start := now.Truncate(span); !current.start.Equal(start) {
+			*current = bucket{start: start}
+		}
+		current.total++
+		cb.consecutive++
+		if failure {
+			current.failures++
+		} else {
+			cb.consecutive = 0
+		}
+
+		if failure && b.shouldTrip(cb, now) {
+			b.transit(key, cb, StateOpen)
+		}
+	}
+}
+
+// release gives back the probe slot taken by a request without recording its result.
+func (b *CircuitBreaker) release(key string, generation int) {
+	b.mu.Lock()
+	defer b.unlock()
+
+	if cb := b.circuits[key]; cb.generation == generation && cb.state == StateHalfOpen {
+		cb.probes--
+	}
+}
+
+func (b *CircuitBreaker) shouldTrip(cb *circuit, now time.Time) bool {
+	if b.ConsecutiveFailures > 0 && cb.consecutive >= b.ConsecutiveFailures {
+		return true
+	}
+
+	if b.FailureRatio <= 0 {
+		return false
+	}
+
+	total, failures := 0, 0
+	for _, bk := range cb.buckets {
+		if now.Sub(bk.start) < b.window() {
+			total += bk.total
+			failures += bk.failures
+		}
+	}
+	return total > 0 && total >= b.MinRequests && float64(failures)/float64(total) >= b.FailureRatio
+}
+
+func (b *CircuitBreaker) transit(key string, cb *circuit, to CircuitState) {
+	from := cb.state
+	*cb = circuit{state: to, generation: cb.generation + 1}
+	if to == StateOpen {
+		cb.openedAt = b.clock()
+	}
+
+	if from != to {
+		b.transitions = append(b.transitions, transition{key, from, to})
+	}
+}
+
+type transition struct {
+	key      string
+	from, to CircuitState
+}
+
+// unlock releases the lock before calling OnStateChange with the transitions made under it,
+// so that the callback may call methods of the breaker such as State.
+func (b *CircuitBreaker) unlock() {
+	transitions := b.transitions
+	b.transitions = nil
+	b.mu.Unlock()
+
+	if b.OnStateChange != nil {
+		for _, t := range transitions {
+			b.OnStateChange(t.key, t.from, t.to)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package sugar
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func TestCircuitBreaker_Opens_After_Consecutive_Failures(t *testing.T) {
+	clock := &fakeClock{t: time.Now()}
+	var changes []CircuitState
+	b := &CircuitBreaker{ConsecutiveFailures: 2, OpenTimeout: time.Second, now: clock.now,
+		OnStateChange: func(key string, from, to CircuitState) {
+			assert.Equal(t, "api.example.com", key)
+			changes = append(changes, to)
+		}}
+	m := &mockTransporter{error: &net.OpError{}}
+
+	newPluginContext(m, http.MethodGet, "http://api.example.com/books", b).Next()
+	newPluginContext(m, http.MethodGet, "http://api.example.com/books", b).Next()
+	err := newPluginContext(m, http.MethodGet, "http://api.example.com/books", b).Next()
+
+	assert.Equal(t, ErrCircuitOpen, err)
+	assert.Equal(t, 2, m.count)
+	assert.Equal(t, StateOpen, b.State("api.example.com"))
+
+	clock.t = clock.t.Add(time.Second)
+	assert.Equal(t, StateHalfOpen, b.State("api.example.com"))
+	m.error, m.response = nil, &http.Response{StatusCode: http.StatusOK}
+	err = newPluginContext(m, http.MethodGet, "http://api.example.com/books", b).Next()
+
+	assert.Nil(t, err)
+	assert.Equal(t, StateClosed, b.State("api.example.com"))
+	assert.Equal(t, []CircuitState{StateOpen, StateHalfOpen, StateClosed}, changes)
+}
+
+func TestCircuitBreaker_Reopens_If_Probe_Fails(t *testing.T) {
+	clock := &fakeClock{t: time.Now()}
+	b := &CircuitBreaker{ConsecutiveFailures: 1, OpenTimeout: time.Second, now: clock.now}
+	m := &mockTransporter{response: &http.Response{StatusCode: http.StatusInternalServerError}}
+
+	newPluginContext(m, http.MethodGet, "http://api.example.com/books", b).Next()
+	clock.t = clock.t.Add(time.Second)
+	newPluginContext(m, http.MethodGet, "http://api.example.com/books", b).Next()
+
+	assert.Equal(t, 2, m.count)
+	assert.Equal(t, StateOpen, b.State("api.example.com"))
+}
+
+func TestCircuitBreaker_Ignores_Canceled_Probe(t *testing.T) {
+	clock := &fakeClock{t: time.Now()}
+	b := &CircuitBreaker{ConsecutiveFailures: 2, OpenTimeout: time.Second, now: clock.now}
+	m := &mockTransporter{error: &net.OpError{}}
+
+	newPluginContext(m, http.MethodGet, "http://api.example.com/books", b).Next()
+	m.error = context.Canceled
+	newPluginContext(m, http.MethodGet, "http://api.example.com/books", b).Next()
+	m.error = &net.OpError{}
+	newPluginContext(m, http.MethodGet, "http://api.example.com/books", b).Next()
+	assert.Equal(t, StateOpen, b.State("api.example.com"))
+
+	clock.t = clock.t.Add(time.Second)
+	m.error = context.Canceled
+	err := newPluginContext(m, http.MethodGet, "http://api.example.com/books", b).Next()
+
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, StateHalfOpen, b.State("api.example.com"))
+
+	m.error = &net.OpError{}
+	newPluginContext(m, http.MethodGet, "http://api.example.com/books", b).Next()
+
+	assert.Equal(t, 5, m.count)
+	assert.Equal(t, StateOpen, b.State("api.example.com"))
+}
+
+func TestCircuitBreaker_Opens_When_Failure_Ratio_Is_Reached(t *testing.T) {
+	b := &CircuitBreaker{FailureRatio: 0.5, MinRequests: 4, Window: time.Minute}
+	ok := &mockTransporter{response: &http.Response{StatusCode: http.StatusOK}}
+	bad := &mockTransporter{response: &http.Response{StatusCode: http.StatusBadGateway}}
+
+	newPluginContext(ok, http.MethodGet, "http://api.example.com/books", b).Next()
+	newPluginContext(bad, http.MethodGet, "http://api.example.com/books", b).Next()
+	newPluginContext(ok, http.MethodGet, "http://api.example.com/books", b).Next()
+	assert.Equal(t, StateClosed, b.State("api.example.com"))
+
+	newPluginContext(bad, http.MethodGet, "http://api.example.com/books", b).Next()
+	assert.Equal(t, StateOpen, b.State("api.example.com"))
+}
+
+func TestCircuitBreaker_Isolates_Circuits_By_Key(t *testing.T) {
+	b := &CircuitBreaker{ConsecutiveFailures: 1, Key: RouteKey}
+	bad := &mockTransporter{response: &http.Response{StatusCode: http.StatusInternalServerError}}
+	ok := &mockTransporter{response: &http.Response{StatusCode: http.StatusOK}}
+
+	newPluginContext(bad, http.MethodGet, "http://api.example.com/books/:id", b).Next()
+	err := newPluginContext(ok, http.MethodGet, "http://api.example.com/authors", b).Next()
+
+	assert.Nil(t, err)
+	assert.Equal(t, StateOpen, b.State("GET api.example.com/books/:id"))
+	assert.Equal(t, StateClosed, b.State("GET api.example.com/authors"))
+}
+
+func TestCircuitBreaker_OnStateChange_Can_Call_State(t *testing.T) {
+	var states []CircuitState
+	b := &CircuitBreaker{ConsecutiveFailures: 1}
+	b.OnStateChange = func(key string, from, to CircuitState) {
+		states = append(states, b.State(key))
+	}
+	m := &mockTransporter{error: &net.OpError{}}
+
+	newPluginContext(m, http.MethodGet, "http://api.example.com/books", b).Next()
+
+	assert.Equal(t, []CircuitState{StateOpen}, states)
+}
+
+func TestCircuitBreaker_Tiny_Window(t *testing.T) {
+	b := &CircuitBreaker{FailureRatio: 0.5, MinRequests: 1, Window: 5 * time.Nanosecond}
+	bad := &mockTransporter{response: &http.Response{StatusCode: http.StatusBadGateway}}
+
+	assert.NotPanics(t, func() {
+		newPluginContext(bad, http.MethodGet, "http://api.example.com/books", b).Next()
+	})
+}
+
+func TestCircuitState_String(t *testing.T) {
+	assert.Equal(t, "closed", StateClosed.String())
+	assert.Equal(t, "open", StateOpen.String())
+	assert.Equal(t, "half-open", StateHalfOpen.String())
+}
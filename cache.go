@@ -0,0 +1,399 @@
+package sugar
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheStatusHeader is added to responses served by the Cache plugin.
+const CacheStatusHeader = "X-Sugar-Cache"
+
+const (
+	// CacheHit means a fresh response is served from the cache without a request.
+	CacheHit = "HIT"
+	// CacheRevalidated means a cached response is served after the server responds with 304 Not Modified.
+	CacheRevalidated = "REVALIDATED"
+	// CacheStale means a stale response is served because the request failed.
+	CacheStale = "STALE"
+)
+
+// CachedResponse is an entry of a CacheStore.
+type CachedResponse struct {
+	StatusCode   int
+	Header       http.Header
+	Body         []byte
+	Vary         map[string]string
+	RequestTime  time.Time
+	ResponseTime time.Time
+}
+
+// CacheStore keeps cached responses.
+type CacheStore interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, entry *CachedResponse)
+	Delete(key string)
+}
+
+// Cache is a plugin which caches responses of GET and HEAD requests according to RFC 7234.
+// It must be used as a pointer, e.g. UsePlugin(&Cache{Store: NewMemoryCacheStore(100)}).
+type Cache struct {
+	// Store keeps cached responses, default an in-memory store of 1000 entries.
+	Store CacheStore
+	// StaleIfError serves a stale response when the request fails or the server responds with 5xx.
+	StaleIfError bool
+
+	once sync.Once
+	now  func() time.Time
+}
+
+func (p *Cache) clock() time.Time {
+	if p.now != nil {
+		return p.now()
+	}
+	return time.Now()
+}
+
+// Handle serves fresh responses from the store, revalidates stale ones and stores new cacheable responses.
+func (p *Cache) Handle(c *Context) error {
+	p.once.Do(func() {
+		if p.Store == nil {
+			p.Store = NewMemoryCacheStore(1000)
+		}
+	})
+
+	req := c.Request
+	key := req.Method + " " + req.URL.String()
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		err := c.Next()
+		if err == nil && c.Response != nil && c.Response.StatusCode < http.StatusBadRequest {
+			p.Store.Delete(http.MethodGet + " " + req.URL.String())
+			p.Store.Delete(http.MethodHead + " " + req.URL.String())
+		}
+		return err
+	}
+
+	reqControl := parseCacheControl(req.Header)
+	if _, ok := reqControl["no-store"]; ok {
+		return c.Next()
+	}
+
+	entry, ok := p.Store.Get(key)
+	if ok && !entry.matches(req) {
+		entry, ok = nil, false
+	}
+
+	if ok {
+		_, noCache := reqControl["no-cache"]
+		if !noCache && p.fresh(entry) {
+			c.Response = entry.response(req, CacheHit, p.clock())
+			return nil
+		}
+
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	requestTime := p.clock()
+	err := c.Next()
+	resp := c.Response
+
+	if ok && (err != nil || (resp != nil && resp.StatusCode >= http.StatusInternalServerError)) && p.StaleIfError {
+		discard(resp)
+		c.Response = entry.response(req, CacheStale, p.clock())
+		return nil
+	}
+
+	if err != nil || resp == nil {
+		return err
+	}
+
+	if ok && resp.StatusCode == http.StatusNotModified {
+		discard(resp)
+		updated := *entry
+		updated.Header = entry.Header.Clone()
+		for k, v := range resp.Header {
+			updated.Header[k] = v
+		}
+		updated.RequestTime, updated.ResponseTime = requestTime, p.clock()
+		p.Store.Set(key, &updated)
+		c.Response = updated.response(req, CacheRevalidated, p.clock())
+		return nil
+	}
+
+	if !cacheable(resp) {
+		return nil
+	}
+
+	var body []byte
+	if resp.Body != nil {
+		body, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	vary := map[string]string{}
+	for _, name := range headerValues(resp.Header, "Vary") {
+		vary[http.CanonicalHeaderKey(name)] = req.Header.Get(name)
+	}
+	p.Store.Set(key, &CachedResponse{
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+		Vary:         vary,
+		RequestTime:  requestTime,
+		ResponseTime: p.clock(),
+	})
+	return nil
+}
+
+// fresh reports whether the age of an entry is less than its freshness lifetime.
+func (p *Cache) fresh(entry *CachedResponse) bool {
+	control := parseCacheControl(entry.Header)
+	if _, ok := control["no-cache"]; ok {
+		return false
+	}
+
+	age := p.clock().Sub(entry.ResponseTime)
+	if v, err := strconv.Atoi(entry.Header.Get("Age")); err == nil {
+		age += time.Duration(v) * time.Second
+	}
+	return age < freshnessLifetime(entry.Header, control)
+}
+
+func freshnessLifetime(header http.Header, control map[string]string) time.Duration {
+	if v, ok := control["max-age"]; ok {
+		if seconds, err := strconv.Atoi(v); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		return 0
+	}
+
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		return 0
+	}
+
+	if v := header.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return 0
+		}
+		return expires.Sub(date)
+	}
+
+	// Heuristic freshness is 10% of the time since the resource was modified.
+	if lastModified, err := http.ParseTime(header.Get("Last-Modified")); err == nil && date.After(lastModified) {
+		return date.Sub(lastModified) / 10
+	}
+	return 0
+}
+
+func cacheable(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMultipleChoices,
+		http.StatusMovedPermanently, http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusGone,
+		http.StatusRequestURITooLong, http.StatusNotImplemented:
+	default:
+		return false
+	}
+
+	control := parseCacheControl(resp.Header)
+	if _, ok := control["no-store"]; ok {
+		return false
+	}
+	for _, v := range headerValues(resp.Header, "Vary") {
+		if v == "*" {
+			return false
+		}
+	}
+
+	_, maxAge := control["max-age"]
+	_, noCache := control["no-cache"]
+	return maxAge || noCache || resp.Header.Get("Expires") != "" || resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+}
+
+func (e *CachedResponse) matches(req *http.Request) bool {
+	for name, value := range e.Vary {
+		if req.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *CachedResponse) response(req *http.Request, status string, now time.Time) *http.Response {
+	header := e.Header.Clone()
+	age := now.Sub(e.ResponseTime)
+	if v, err := strconv.Atoi(header.Get("Age")); err == nil {
+		age += time.Duration(v) * time.Second
+	}
+	header.Set("Age", strconv.Itoa(int(age.Seconds())))
+	header.Set(CacheStatusHeader, status)
+
+	return NewResponse(req, e.StatusCode, header, e.Body)
+}
+
+func parseCacheControl(header http.Header) map[string]string {
+	control := map[string]string{}
+	for _, directive := range headerValues(header, "Cache-Control") {
+		kv := strings.SplitN(directive, "=", 2)
+		name := strings.ToLower(strings.TrimSpace(kv[0]))
+		if len(kv) == 2 {
+			control[name] = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		} else {
+			control[name] = ""
+		}
+	}
+	return control
+}
+
+func headerValues(header http.Header, name string) []string {
+	var values []string
+	for _, line := range header.Values(name) {
+		for _, v := range strings.Split(line, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+// MemoryCacheStore is an in-memory LRU CacheStore.
+type MemoryCacheStore struct {
+	capacity int
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List
+}
+
+type memoryCacheItem struct {
+	key   string
+	entry *CachedResponse
+}
+
+// NewMemoryCacheStore returns a MemoryCacheStore which keeps at most capacity entries.
+func NewMemoryCacheStore(capacity int) *MemoryCacheStore {
+	return &MemoryCacheStore{capacity: capacity, entries: map[string]*list.Element{}, lru: list.New()}
+}
+
+// Get returns an entry and marks it as recently used.
+func (s *MemoryCacheStore) Get(key string) (*CachedResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		s.lru.MoveToFront(e)
+		return e.Value.(*memoryCacheItem).entry, true
+	}
+	return nil, false
+}
+
+// Set adds an entry and evicts the least recently used one if the store is full.
+func (s *MemoryCacheStore) Set(key string, entry *CachedResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		e.Value.(*memoryCacheItem).entry = entry
+		s.lru.MoveToFront(e)
+		return
+	}
+
+	s.entries[key] = s.lru.PushFront(&memoryCacheItem{key: key, entry: entry})
+	if s.capacity > 0 && s.lru.Len() > s.capacity {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryCacheItem).key)
+	}
+}
+
+// Delete removes an entry.
+func (s *MemoryCacheStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		s.lru.Remove(e)
+		delete(s.entries, key)
+	}
+}
+
+// DiskCacheStore is a CacheStore which keeps entries as JSON files in a directory.
+type DiskCacheStore struct {
+	Dir string
+}
+
+// NewDiskCacheStore returns a DiskCacheStore and creates the directory if it does not exist.
+func NewDiskCacheStore(dir string) (*DiskCacheStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DiskCacheStore{Dir: dir}, nil
+}
+
+func (s *DiskCacheStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.Dir, hex.EncodeToString(sum[:]))
+}
+
+// Get reads an entry from its file.
+func (s *DiskCacheStore) Get(key string) (*CachedResponse, bool) {
+	b, err := ioutil.ReadFile(s.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	entry := &CachedResponse{}
+	if err := json.Unmarshal(b, entry); err != nil {
+		return nil, false
+	}
+	return entry, true
+}
+
+// Set writes an entry into a temporary file and then renames it.
+func (s *DiskCacheStore) Set(key string, entry *CachedResponse) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	f, err := ioutil.TempFile(s.Dir, "tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(b)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return
+	}
+	if err := os.Rename(f.Name(), s.path(key)); err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+// Delete removes the file of an entry.
+func (s *DiskCacheStore) Delete(key string) {
+	os.Remove(s.path(key))
+}
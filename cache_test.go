@@ -0,0 +1,192 @@
+package sugar
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type cacheTransporter struct {
+	requests []*http.Request
+	respond  func(req *http.Request) (*http.Response, error)
+}
+
+func (t *cacheTransporter) Do(req *http.Request) (*http.Response, error) {
+	t.requests = append(t.requests, req)
+	return t.respond(req)
+}
+
+func textResponse(status int, header http.Header, body string) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{StatusCode: status, Header: header, Body: ioutil.NopCloser(strings.NewReader(body))}
+}
+
+func sendCached(p *Cache, m Transporter, header http.Header) (*http.Response, error) {
+	c := newPluginContext(m, http.MethodGet, "http://api.example.com/books", p)
+	for k, v := range header {
+		c.Request.Header[k] = v
+	}
+	err := c.Next()
+	return c.Response, err
+}
+
+func readBody(resp *http.Response) string {
+	b, _ := ioutil.ReadAll(resp.Body)
+	return string(b)
+}
+
+func TestCache_Serves_Fresh_Response(t *testing.T) {
+	p := &Cache{Store: NewMemoryCacheStore(10)}
+	m := &cacheTransporter{respond: func(req *http.Request) (*http.Response, error) {
+		return textResponse(http.StatusOK, http.Header{"Cache-Control": {"max-age=60"}}, "bookA"), nil
+	}}
+
+	resp, _ := sendCached(p, m, nil)
+	assert.Equal(t, "bookA", readBody(resp))
+	assert.Equal(t, "", resp.Header.Get(CacheStatusHeader))
+
+	resp, err := sendCached(p, m, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(m.requests))
+	assert.Equal(t, CacheHit, resp.Header.Get(CacheStatusHeader))
+	assert.Equal(t, "bookA", readBody(resp))
+
+	sendCached(p, m, http.Header{"Cache-Control": {"no-cache"}})
+	assert.Equal(t, 2, len(m.requests))
+}
+
+func TestCache_Revalidates_With_ETag(t *testing.T) {
+	now := time.Now()
+	p := &Cache{Store: NewMemoryCacheStore(10), now: func() time.Time { return now }}
+	m := &cacheTransporter{respond: func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			return textResponse(http.StatusNotModified, http.Header{"Cache-Control": {"max-age=0"}}, ""), nil
+		}
+		return textResponse(http.StatusOK, http.Header{"Etag": {`"v1"`}, "Cache-Control": {"no-cache"}}, "bookA"), nil
+	}}
+
+	sendCached(p, m, nil)
+	resp, err := sendCached(p, m, nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(m.requests))
+	assert.Equal(t, CacheRevalidated, resp.Header.Get(CacheStatusHeader))
+	assert.Equal(t, "bookA", readBody(resp))
+}
+
+func TestCache_Not_Store_If_No_Store(t *testing.T) {
+	p := &Cache{Store: NewMemoryCacheStore(10)}
+	m := &cacheTransporter{respond: func(req *http.Request) (*http.Response, error) {
+		return textResponse(http.StatusOK, http.Header{"Cache-Control": {"no-store, max-age=60"}}, "bookA"), nil
+	}}
+
+	sendCached(p, m, nil)
+	sendCached(p, m, nil)
+
+	assert.Equal(t, 2, len(m.requests))
+}
+
+func TestCache_Honors_Vary(t *testing.T) {
+	p := &Cache{Store: NewMemoryCacheStore(10)}
+	m := &cacheTransporter{respond: func(req *http.Request) (*http.Response, error) {
+		return textResponse(http.StatusOK, http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"Accept-Language"}}, req.Header.Get("Accept-Language")), nil
+	}}
+
+	sendCached(p, m, http.Header{"Accept-Language": {"en"}})
+	resp, _ := sendCached(p, m, http.Header{"Accept-Language": {"zh"}})
+
+	assert.Equal(t, 2, len(m.requests))
+	assert.Equal(t, "zh", readBody(resp))
+}
+
+func TestCache_Serves_Stale_On_Error(t *testing.T) {
+	now := time.Now()
+	p := &Cache{Store: NewMemoryCacheStore(10), StaleIfError: true, now: func() time.Time { return now }}
+	m := &cacheTransporter{respond: func(req *http.Request) (*http.Response, error) {
+		return textResponse(http.StatusOK, http.Header{"Cache-Control": {"max-age=1"}}, "bookA"), nil
+	}}
+	sendCached(p, m, nil)
+
+	now = now.Add(time.Minute)
+	m.respond = func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}
+	resp, err := sendCached(p, m, nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, CacheStale, resp.Header.Get(CacheStatusHeader))
+	assert.Equal(t, "bookA", readBody(resp))
+}
+
+func TestCache_Invalidates_On_Unsafe_Method(t *testing.T) {
+	p := &Cache{Store: NewMemoryCacheStore(10)}
+	m := &cacheTransporter{respond: func(req *http.Request) (*http.Response, error) {
+		return textResponse(http.StatusOK, http.Header{"Cache-Control": {"max-age=60"}}, "bookA"), nil
+	}}
+	sendCached(p, m, nil)
+
+	newPluginContext(m, http.MethodPost, "http://api.example.com/books", p).Next()
+	sendCached(p, m, nil)
+
+	assert.Equal(t, 3, len(m.requests))
+}
+
+func TestFreshnessLifetime(t *testing.T) {
+	date := time.Now().UTC()
+	header := http.Header{
+		"Date":    {date.Format(http.TimeFormat)},
+		"Expires": {date.Add(time.Hour).Format(http.TimeFormat)},
+	}
+	assert.Equal(t, time.Hour, freshnessLifetime(header, parseCacheControl(header)))
+
+	header = http.Header{
+		"Date":          {date.Format(http.TimeFormat)},
+		"Last-Modified": {date.Add(-10 * time.Hour).Format(http.TimeFormat)},
+	}
+	assert.Equal(t, time.Hour, freshnessLifetime(header, parseCacheControl(header)))
+}
+
+func TestMemoryCacheStore_Evicts_Least_Recently_Used(t *testing.T) {
+	s := NewMemoryCacheStore(2)
+	s.Set("a", &CachedResponse{})
+	s.Set("b", &CachedResponse{})
+	s.Get("a")
+	s.Set("c", &CachedResponse{})
+
+	_, ok := s.Get("b")
+	assert.False(t, ok)
+	_, ok = s.Get("a")
+	assert.True(t, ok)
+	s.Delete("a")
+	_, ok = s.Get("a")
+	assert.False(t, ok)
+}
+
+func TestDiskCacheStore(t *testing.T) {
+	s, err := NewDiskCacheStore(t.TempDir())
+	assert.Nil(t, err)
+
+	s.Set("key", &CachedResponse{StatusCode: http.StatusOK, Header: http.Header{"Etag": {"v1"}}, Body: []byte("bookA")})
+	entry, ok := s.Get("key")
+
+	assert.True(t, ok)
+	assert.Equal(t, "bookA", string(entry.Body))
+	assert.Equal(t, "v1", entry.Header.Get("ETag"))
+
+	s.Delete("key")
+	_, ok = s.Get("key")
+	assert.False(t, ok)
+}
+
+func TestResponse_CacheStatus(t *testing.T) {
+	resp := &Response{Response: http.Response{Header: http.Header{CacheStatusHeader: {CacheHit}}}}
+	assert.Equal(t, CacheHit, resp.CacheStatus())
+	assert.Equal(t, "", (&Response{}).CacheStatus())
+}
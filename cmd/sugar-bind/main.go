@@ -0,0 +1,414 @@
+// Command sugar-bind generates sugar clients from annotated interfaces.
+//
+// Each method of the interface declares its endpoint in the doc comment,
+// and optionally maps its arguments onto params in the same way as the params tag of sugar.Bind:
+//
+//	//go:generate sugar-bind -type BookApi
+//	type BookApi interface {
+//		// GET /books/:id
+//		// params: path:id,query:page
+//		GetBook(ctx context.Context, id int, page int) (*Book, error)
+//	}
+//
+// Without a params annotation, arguments named after path variables are encoded as Path{} params
+// and the others are passed to encoders as they are.
+// A constructor named New<Type> is generated into <type>_sugar.go.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	typeName = flag.String("type", "", "comma-separated list of interface names; required")
+	output   = flag.String("output", "", "output file name; default <type>_sugar.go")
+	dir      = flag.String("dir", ".", "directory of the package")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("sugar-bind: ")
+	flag.Parse()
+	if *typeName == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	pkg, files, err := parseDir(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	src, err := generate(pkg, files, strings.Split(*typeName, ","))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	name := *output
+	if name == "" {
+		name = strings.ToLower(strings.Split(*typeName, ",")[0]) + "_sugar.go"
+	}
+	if err := ioutil.WriteFile(filepath.Join(*dir, name), src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func parseDir(dir string) (string, []*ast.File, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for name, pkg := range pkgs {
+		var files []*ast.File
+		for _, f := range pkg.Files {
+			files = append(files, f)
+		}
+		return name, files, nil
+	}
+	return "", nil, fmt.Errorf("no package found in %s", dir)
+}
+
+type method struct {
+	name       string
+	httpMethod string
+	url        string
+	params     []param
+	variadic   string
+	hasContext bool
+	results    []string
+}
+
+type param struct {
+	name string
+	typ  string
+	expr string
+	// path is the path variable of the argument. Path variables of a method are merged into a single sugar.Path{},
+	// because PathEncoder replaces every variable and one missing from the current Path{} becomes empty.
+	path string
+}
+
+var pathVariable = regexp.MustCompile(`:([^/]+)`)
+
+// reservedNames are the receiver, locals and packages referenced by generated method bodies.
+var reservedNames = []string{"c", "params", "p", "r", "out", "resp", "err", "sugar", "context"}
+
+func generate(pkg string, files []*ast.File, names []string) ([]byte, error) {
+	imports := map[string]string{}
+	b := &bytes.Buffer{}
+	for _, name := range names {
+		iface, file := findInterface(files, name)
+		if iface == nil {
+			return nil, fmt.Errorf("interface %s not found", name)
+		}
+
+		var methods []method
+		for _, field := range iface.Methods.List {
+			fn, ok := field.Type.(*ast.FuncType)
+			if !ok || len(field.Names) == 0 {
+				return nil, fmt.Errorf("%s: embedded interfaces are not supported", name)
+			}
+
+			m, err := parseMethod(field.Names[0].Name, field.Doc, fn)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", name, field.Names[0].Name, err)
+			}
+			methods = append(methods, m)
+			collectImports(file, fn, imports)
+			if !m.hasContext {
+				imports["context"] = strconv.Quote("context")
+			}
+		}
+
+		writeClient(b, name, methods)
+	}
+
+	header := &bytes.Buffer{}
+	fmt.Fprintf(header, "// Code generated by sugar-bind. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	imports["sugar"] = strconv.Quote("github.com/pojozhang/sugar")
+	imports["strings"] = strconv.Quote("strings")
+	var keys []string
+	for k := range imports {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if path, _ := strconv.Unquote(imports[k]); filepath.Base(path) == k {
+			fmt.Fprintf(header, "\t%s\n", imports[k])
+		} else {
+			fmt.Fprintf(header, "\t%s %s\n", k, imports[k])
+		}
+	}
+	header.WriteString(")\n")
+	header.Write(b.Bytes())
+
+	return format.Source(header.Bytes())
+}
+
+func findInterface(files []*ast.File, name string) (*ast.InterfaceType, *ast.File) {
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if iface, ok := ts.Type.(*ast.InterfaceType); ok && ts.Name.Name == name {
+					return iface, f
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+func parseMethod(name string, doc *ast.CommentGroup, fn *ast.FuncType) (method, error) {
+	m := method{name: name}
+	var mapping []string
+	if doc != nil {
+		for _, c := range doc.List {
+			line := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+			if strings.HasPrefix(line, "params:") {
+				mapping = strings.Split(strings.TrimSpace(strings.TrimPrefix(line, "params:")), ",")
+				continue
+			}
+
+			parts := strings.Fields(line)
+			if len(parts) == 2 && isHttpMethod(parts[0]) {
+				m.httpMethod, m.url = parts[0], parts[1]
+			}
+		}
+	}
+	if m.httpMethod == "" {
+		return m, fmt.Errorf("missing endpoint annotation, e.g. // GET /books/:id")
+	}
+
+	pathVariables := map[string]bool{}
+	for _, match := range pathVariable.FindAllStringSubmatch(m.url, -1) {
+		pathVariables[match[1]] = true
+	}
+
+	// Arguments must not shadow the receiver, the locals and packages of the generated method body,
+	// or the result types, so clashing and blank ones are renamed.
+	taken := map[string]bool{"_": true}
+	for _, name := range reservedNames {
+		taken[name] = true
+	}
+	if fn.Results != nil {
+		ast.Inspect(fn.Results, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				taken[id.Name] = true
+			}
+			return true
+		})
+	}
+	var declared []string
+	for _, field := range fn.Params.List {
+		for _, n := range field.Names {
+			declared = append(declared, n.Name)
+		}
+	}
+
+	i := 0
+	for _, field := range fn.Params.List {
+		typ := types.ExprString(field.Type)
+		names := field.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{ast.NewIdent("_")}
+		}
+
+		for _, n := range names {
+			local := n.Name
+			for j := i; taken[local] || (local != n.Name && contains(declared, local)); j++ {
+				local = "arg" + strconv.Itoa(j)
+			}
+			taken[local] = true
+
+			if i == 0 && typ == "context.Context" {
+				m.hasContext = true
+				m.params = append(m.params, param{name: local, typ: typ})
+				i++
+				continue
+			}
+
+			p := param{name: local, typ: typ}
+			if _, ok := field.Type.(*ast.Ellipsis); ok {
+				m.variadic = local
+				m.params = append(m.params, p)
+				i++
+				continue
+			}
+
+			index := i
+			if m.hasContext {
+				index--
+			}
+			expr, path, err := paramExpr(n.Name, local, index, mapping, pathVariables)
+			if err != nil {
+				return m, err
+			}
+			p.expr, p.path = expr, path
+			m.params = append(m.params, p)
+			i++
+		}
+	}
+
+	if fn.Results != nil {
+		for _, field := range fn.Results.List {
+			n := len(field.Names)
+			if n == 0 {
+				n = 1
+			}
+			for j := 0; j < n; j++ {
+				m.results = append(m.results, types.ExprString(field.Type))
+			}
+		}
+	}
+
+	switch {
+	case len(m.results) == 0 || len(m.results) > 3 || m.results[len(m.results)-1] != "error":
+		return m, fmt.Errorf("must return an error as its last result")
+	case len(m.results) == 3 && m.results[1] != "*http.Response":
+		return m, fmt.Errorf("must return *http.Response as its second result")
+	}
+	return m, nil
+}
+
+// paramExpr returns the expression of an argument, or the path variable it is mapped onto.
+// The name of the argument matches path variables and local is the identifier used in the generated code.
+func paramExpr(name, local string, index int, mapping []string, pathVariables map[string]bool) (string, string, error) {
+	if mapping == nil {
+		if pathVariables[name] {
+			return "", name, nil
+		}
+		return local, "", nil
+	}
+
+	if index >= len(mapping) {
+		return local, "", nil
+	}
+
+	kind, key := strings.TrimSpace(mapping[index]), ""
+	if j := strings.Index(kind, ":"); j >= 0 {
+		kind, key = kind[:j], kind[j+1:]
+	}
+
+	switch kind {
+	case "":
+		return local, "", nil
+	case "path":
+		return "", key, nil
+	case "query", "header", "cookie", "form":
+		return fmt.Sprintf("sugar.%s{%q: %s}", strings.ToUpper(kind[:1])+kind[1:], key, local), "", nil
+	case "json":
+		return fmt.Sprintf("sugar.Json{Payload: %s}", local), "", nil
+	case "xml":
+		return fmt.Sprintf("sugar.Xml{Payload: %s}", local), "", nil
+	}
+	return "", "", fmt.Errorf("unknown param kind %q", kind)
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isHttpMethod(s string) bool {
+	switch s {
+	case "GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS":
+		return true
+	}
+	return false
+}
+
+func collectImports(file *ast.File, fn *ast.FuncType, imports map[string]string) {
+	used := map[string]bool{}
+	ast.Inspect(fn, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				used[id.Name] = true
+			}
+		}
+		return true
+	})
+
+	for _, spec := range file.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		name := filepath.Base(path)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if used[name] {
+			imports[name] = spec.Path.Value
+		}
+	}
+}
+
+func writeClient(b *bytes.Buffer, name string, methods []method) {
+	impl := strings.ToLower(name[:1]) + name[1:] + "Client"
+	fmt.Fprintf(b, "\ntype %s struct {\n\tclient  *sugar.Client\n\tbaseUrl string\n}\n", impl)
+	fmt.Fprintf(b, "\n// New%s returns a %s which sends requests via the client.\n", name, name)
+	fmt.Fprintf(b, "func New%s(client *sugar.Client, baseUrl string) %s {\n", name, name)
+	fmt.Fprintf(b, "\treturn &%s{client: client, baseUrl: strings.TrimRight(baseUrl, \"/\")}\n}\n", impl)
+
+	for _, m := range methods {
+		var signature, params, path []string
+		ctx := "context.Background()"
+		for _, p := range m.params {
+			signature = append(signature, p.name+" "+p.typ)
+			if p.path != "" {
+				path = append(path, fmt.Sprintf("%q: %s", p.path, p.name))
+			} else if p.expr != "" {
+				params = append(params, p.expr)
+			}
+		}
+		if len(path) > 0 {
+			params = append([]string{"sugar.Path{" + strings.Join(path, ", ") + "}"}, params...)
+		}
+		if m.hasContext {
+			ctx = m.params[0].name
+		}
+
+		fmt.Fprintf(b, "\nfunc (c *%s) %s(%s) (%s) {\n", impl, m.name, strings.Join(signature, ", "), strings.Join(m.results, ", "))
+		fmt.Fprintf(b, "\tparams := []interface{}{%s}\n", strings.Join(params, ", "))
+		if m.variadic != "" {
+			fmt.Fprintf(b, "\tfor _, p := range %s {\n\t\tparams = append(params, p)\n\t}\n", m.variadic)
+		}
+		fmt.Fprintf(b, "\tr := c.client.Do(%s, %q, c.baseUrl+%q, params...)\n", ctx, m.httpMethod, m.url)
+
+		switch {
+		case len(m.results) == 1:
+			b.WriteString("\tdefer r.Close()\n\t_, err := r.Raw()\n\treturn err\n")
+		case m.results[0] == "*http.Response":
+			b.WriteString("\treturn r.Raw()\n")
+		case len(m.results) == 2:
+			fmt.Fprintf(b, "\tvar out %s\n\t_, err := r.Read(&out)\n\treturn out, err\n", m.results[0])
+		default:
+			fmt.Fprintf(b, "\tvar out %s\n\tresp, err := r.Read(&out)\n\treturn out, resp, err\n", m.results[0])
+		}
+		b.WriteString("}\n")
+	}
+}
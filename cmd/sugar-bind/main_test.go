@@ -0,0 +1,140 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const source = `package api
+
+import (
+	"context"
+	"net/http"
+)
+
+type Book struct {
+	Title string
+}
+
+type BookApi interface {
+	// GET /books/:id
+	GetBook(ctx context.Context, id int) (*Book, error)
+	// GET /books
+	// params: query:page,header:X-Trace
+	ListBooks(ctx context.Context, page int, trace string) ([]Book, *http.Response, error)
+	// DELETE /books/:id
+	DeleteBook(id int, opts ...interface{}) error
+}
+`
+
+func parseSource(t *testing.T, src string) []*ast.File {
+	f, err := parser.ParseFile(token.NewFileSet(), "api.go", src, parser.ParseComments)
+	assert.Nil(t, err)
+	return []*ast.File{f}
+}
+
+// sourceImporter is shared by tests, so that the sugar package is type checked once.
+var sourceImporter = importer.ForCompiler(token.NewFileSet(), "source", nil)
+
+// typeCheck checks that generated code compiles along with the interfaces it implements.
+func typeCheck(t *testing.T, src string, generated []byte) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "api.go", src, 0)
+	if !assert.Nil(t, err) {
+		return
+	}
+	g, err := parser.ParseFile(fset, "api_sugar.go", generated, 0)
+	if !assert.Nil(t, err, string(generated)) {
+		return
+	}
+	config := &types.Config{Importer: sourceImporter}
+	_, err = config.Check("api", fset, []*ast.File{f, g}, nil)
+	assert.Nil(t, err, string(generated))
+}
+
+func TestGenerate(t *testing.T) {
+	b, err := generate("api", parseSource(t, source), []string{"BookApi"})
+
+	assert.Nil(t, err)
+	src := string(b)
+	assert.Contains(t, src, "func NewBookApi(client *sugar.Client, baseUrl string) BookApi")
+	assert.Contains(t, src, `params := []interface{}{sugar.Path{"id": id}}`)
+	assert.Contains(t, src, `params := []interface{}{sugar.Query{"page": page}, sugar.Header{"X-Trace": trace}}`)
+	assert.Contains(t, src, `r := c.client.Do(context.Background(), "DELETE", c.baseUrl+"/books/:id", params...)`)
+	assert.Contains(t, src, "\"net/http\"")
+	typeCheck(t, source, b)
+}
+
+func TestGenerate_Merges_Path_Variables(t *testing.T) {
+	api := `package api
+
+import "context"
+
+type AuthorApi interface {
+	// GET /books/:bid/authors/:aid
+	GetAuthor(ctx context.Context, bid int, aid int) error
+	// GET /books/:bid/authors/:aid
+	// params: path:bid,query:lang,path:aid
+	GetAuthorIn(ctx context.Context, book int, lang string, author int) error
+}
+`
+	b, err := generate("api", parseSource(t, api), []string{"AuthorApi"})
+
+	assert.Nil(t, err)
+	src := string(b)
+	assert.Contains(t, src, `params := []interface{}{sugar.Path{"bid": bid, "aid": aid}}`)
+	assert.Contains(t, src, `params := []interface{}{sugar.Path{"bid": book, "aid": author}, sugar.Query{"lang": lang}}`)
+	typeCheck(t, api, b)
+}
+
+func TestGenerate_Renames_Arguments_Clashing_With_Generated_Names(t *testing.T) {
+	api := `package api
+
+import "context"
+
+type Out struct{}
+
+type BookApi interface {
+	// GET /books/:r
+	GetBook(c context.Context, r int, out string) (Out, error)
+	// DELETE /books/:id
+	DeleteBook(ctx context.Context, id int, params ...interface{}) error
+	// GET /books
+	// params: query:err,header:X-Trace
+	ListBooks(ctx context.Context, err int, sugar string, _ bool, arg3 int, Out int) ([]Out, error)
+}
+`
+	b, err := generate("api", parseSource(t, api), []string{"BookApi"})
+
+	assert.Nil(t, err)
+	src := string(b)
+	assert.Contains(t, src, `GetBook(arg0 context.Context, arg1 int, arg2 string) (Out, error)`)
+	assert.Contains(t, src, `params := []interface{}{sugar.Path{"r": arg1}, arg2}`)
+	assert.Contains(t, src, `DeleteBook(ctx context.Context, id int, arg2 ...interface{}) error`)
+	assert.Contains(t, src, `params := []interface{}{sugar.Query{"err": arg1}, sugar.Header{"X-Trace": arg2}, arg4, arg3, arg5}`)
+	assert.Contains(t, src, `ListBooks(ctx context.Context, arg1 int, arg2 string, arg4 bool, arg3 int, arg5 int) ([]Out, error)`)
+	typeCheck(t, api, b)
+}
+
+func TestGenerate_Returns_Error_If_Annotation_Is_Missing(t *testing.T) {
+	_, err := generate("api", parseSource(t, `package api
+
+type BookApi interface {
+	GetBook(id int) error
+}
+`), []string{"BookApi"})
+
+	assert.NotNil(t, err)
+}
+
+func TestGenerate_Returns_Error_If_Interface_Is_Not_Found(t *testing.T) {
+	_, err := generate("api", parseSource(t, source), []string{"AuthorApi"})
+
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,668 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type generator struct {
+	doc   *document
+	pkg   string
+	types bytes.Buffer
+	funcs bytes.Buffer
+	// names are identifiers declared in the package, and methods are methods of Client.
+	names   map[string]bool
+	methods map[string]bool
+	// schemaTypes maps names of component schemas to names of their types.
+	schemaTypes map[string]string
+	client      bool
+	// imports are paths of packages used by the generated code.
+	imports map[string]bool
+}
+
+// reservedNames are declared by the generated client regardless of the document.
+var reservedNames = map[string]bool{"Client": true, "NewClient": true, "Security": true}
+
+func generate(doc *document, pkg string) ([]byte, error) {
+	g := &generator{doc: doc, pkg: pkg, names: map[string]bool{}, methods: map[string]bool{}, schemaTypes: map[string]string{}, imports: map[string]bool{}}
+	for name := range reservedNames {
+		g.names[name] = true
+	}
+
+	// Schemas keep their names, except that a schema called Client, NewClient or Security gets a Schema suffix.
+	// Other generated types are renamed instead if they collide with schemas, see declare.
+	for _, name := range sortedKeys(doc.Components.Schemas) {
+		t := exportName(name)
+		if reservedNames[t] {
+			t += "Schema"
+		}
+		if g.names[t] {
+			return nil, fmt.Errorf("schema %s: type %s is already declared by another schema", name, t)
+		}
+		g.names[t] = true
+		g.schemaTypes[name] = t
+	}
+	for _, name := range sortedKeys(doc.Components.Schemas) {
+		if err := g.namedType(g.schemaTypes[name], doc.Components.Schemas[name]); err != nil {
+			return nil, fmt.Errorf("schema %s: %v", name, err)
+		}
+	}
+
+	g.security()
+
+	for _, path := range sortedKeys(doc.Paths) {
+		item := doc.Paths[path]
+		for _, o := range item.operations() {
+			if err := g.operation(path, o.method, item, o.op); err != nil {
+				return nil, fmt.Errorf("%s %s: %v", o.method, path, err)
+			}
+		}
+	}
+
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "// Code generated by sugar-gen. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	if len(g.imports) > 0 {
+		b.WriteString("import (\n")
+		// Standard packages come first, as goimports groups them.
+		for _, std := range []bool{true, false} {
+			for _, path := range sortedKeys(g.imports) {
+				if isStandard(path) == std {
+					fmt.Fprintf(b, "\t%q\n", path)
+				}
+			}
+		}
+		b.WriteString(")\n\n")
+	}
+	b.Write(g.types.Bytes())
+	b.Write(g.funcs.Bytes())
+
+	return format.Source(b.Bytes())
+}
+
+// declare reserves a name for a generated type, appending a number if the name is taken,
+// e.g. ListBooksParams2 if the document has a schema called ListBooksParams.
+func (g *generator) declare(name string) string {
+	return unique(g.names, name)
+}
+
+func unique(names map[string]bool, name string) string {
+	declared := name
+	for i := 2; names[declared]; i++ {
+		declared = name + strconv.Itoa(i)
+	}
+	names[declared] = true
+	return declared
+}
+
+// use records that the generated code refers to a package.
+func (g *generator) use(paths ...string) {
+	for _, path := range paths {
+		g.imports[path] = true
+	}
+}
+
+func isStandard(path string) bool {
+	return !strings.Contains(strings.Split(path, "/")[0], ".")
+}
+
+// goType returns the Go type of a schema, declaring a named type called name for inline objects.
+func (g *generator) goType(name string, s *schema) (string, error) {
+	if s == nil {
+		return "interface{}", nil
+	}
+
+	if s.Ref != "" {
+		ref, err := refName(s.Ref, "#/components/schemas/")
+		if err != nil {
+			return "", err
+		}
+		t, ok := g.schemaTypes[ref]
+		if !ok {
+			return "", fmt.Errorf("schema %q not found", ref)
+		}
+		return t, nil
+	}
+
+	switch {
+	case len(s.OneOf) > 0 || len(s.AnyOf) > 0:
+		return "interface{}", nil
+	case len(s.AllOf) > 0 || len(s.Properties) > 0:
+		name = g.declare(name)
+		if err := g.namedType(name, s); err != nil {
+			return "", err
+		}
+		return name, nil
+	}
+
+	switch s.Type {
+	case "string":
+		return "string", nil
+	case "integer":
+		if s.Format == "int32" {
+			return "int32", nil
+		}
+		return "int64", nil
+	case "number":
+		if s.Format == "float" {
+			return "float32", nil
+		}
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		item, err := g.goType(name+"Item", s.Items)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + item, nil
+	case "object":
+		return "map[string]interface{}", nil
+	}
+	return "interface{}", nil
+}
+
+// namedType writes the declaration of a type for a schema. The name must be declared by the caller.
+func (g *generator) namedType(name string, s *schema) error {
+	properties, required, err := g.properties(s)
+	if err != nil {
+		return err
+	}
+
+	if len(properties) == 0 && len(s.AllOf) == 0 && s.Type != "object" {
+		t, err := g.goType(name, s)
+		if err != nil {
+			return err
+		}
+		g.comment(&g.types, name, s.Description)
+		fmt.Fprintf(&g.types, "type %s %s\n\n", name, t)
+		return nil
+	}
+
+	var fields bytes.Buffer
+	for _, prop := range sortedKeys(properties) {
+		p := properties[prop]
+		t, err := g.goType(name+exportName(prop), p)
+		if err != nil {
+			return fmt.Errorf("property %s: %v", prop, err)
+		}
+
+		tag := prop
+		if !required[prop] {
+			tag += ",omitempty"
+			// Optional values are pointers, so that zero values such as false and 0 can still be sent.
+			// omitempty never omits a struct value either, and a recursive schema needs a pointer as well.
+			if !g.nillable(p) {
+				t = "*" + t
+			}
+		}
+		if p.Description != "" {
+			fmt.Fprintf(&fields, "\t// %s\n", oneLine(p.Description))
+		}
+		fmt.Fprintf(&fields, "\t%s %s `json:%q`\n", exportName(prop), t, tag)
+	}
+
+	g.comment(&g.types, name, s.Description)
+	fmt.Fprintf(&g.types, "type %s struct {\n%s}\n\n", name, fields.String())
+	return nil
+}
+
+// nillable reports whether goType returns a slice, map or interface type for a schema, which omitempty omits when nil.
+func (g *generator) nillable(s *schema) bool {
+	if s == nil {
+		return true
+	}
+	if s.Ref != "" {
+		return g.declaresNillable(s.Ref, map[string]bool{})
+	}
+	return isNillable(s)
+}
+
+// declaresNillable reports whether namedType declares a component schema as a nillable type, following aliases of other schemas.
+func (g *generator) declaresNillable(ref string, seen map[string]bool) bool {
+	name, err := refName(ref, "#/components/schemas/")
+	s := g.doc.Components.Schemas[name]
+	if err != nil || s == nil || seen[name] {
+		return false
+	}
+	seen[name] = true
+
+	switch {
+	case len(s.Properties) > 0 || len(s.AllOf) > 0 || s.Type == "object":
+		return false
+	case s.Ref != "":
+		return g.declaresNillable(s.Ref, seen)
+	}
+	return isNillable(s)
+}
+
+// isNillable reports whether goType returns a slice, map or interface type for an inline schema.
+func isNillable(s *schema) bool {
+	switch {
+	case len(s.OneOf) > 0 || len(s.AnyOf) > 0:
+		return true
+	case len(s.AllOf) > 0 || len(s.Properties) > 0:
+		return false
+	}
+	switch s.Type {
+	case "string", "integer", "number", "boolean":
+		return false
+	}
+	return true
+}
+
+// properties merges properties of a schema and its allOf schemas.
+func (g *generator) properties(s *schema) (map[string]*schema, map[string]bool, error) {
+	properties, required := map[string]*schema{}, map[string]bool{}
+	for _, sub := range s.AllOf {
+		if sub.Ref != "" {
+			name, err := refName(sub.Ref, "#/components/schemas/")
+			if err != nil {
+				return nil, nil, err
+			}
+			if sub = g.doc.Components.Schemas[name]; sub == nil {
+				return nil, nil, fmt.Errorf("schema %q not found", name)
+			}
+		}
+
+		p, r, err := g.properties(sub)
+		if err != nil {
+			return nil, nil, err
+		}
+		for k, v := range p {
+			properties[k] = v
+		}
+		for k := range r {
+			required[k] = true
+		}
+	}
+
+	for k, v := range s.Properties {
+		properties[k] = v
+	}
+	for _, k := range s.Required {
+		required[k] = true
+	}
+	return properties, required, nil
+}
+
+func (g *generator) security() {
+	schemes := g.doc.Components.SecuritySchemes
+	if len(schemes) == 0 {
+		return
+	}
+
+	var fields, cases bytes.Buffer
+	for _, name := range sortedKeys(schemes) {
+		s, field := schemes[name], exportName(name)
+		switch {
+		case s.Type == "http" && strings.EqualFold(s.Scheme, "basic"):
+			g.use(sugarPackage)
+			fmt.Fprintf(&fields, "\t%s *sugar.User\n", field)
+			fmt.Fprintf(&cases, "\tcase %q:\n\t\tif s.%s != nil {\n\t\t\treturn *s.%s\n\t\t}\n", name, field, field)
+		case s.Type == "http" || s.Type == "oauth2" || s.Type == "openIdConnect":
+			g.use(sugarPackage)
+			fmt.Fprintf(&fields, "\t%s string\n", field)
+			fmt.Fprintf(&cases, "\tcase %q:\n\t\tif s.%s != \"\" {\n\t\t\treturn sugar.Header{\"Authorization\": \"Bearer \" + s.%s}\n\t\t}\n", name, field, field)
+		case s.Type == "apiKey":
+			param := map[string]string{"query": "Query", "cookie": "Cookie"}[s.In]
+			if param == "" {
+				param = "Header"
+			}
+			g.use(sugarPackage)
+			fmt.Fprintf(&fields, "\t%s string\n", field)
+			fmt.Fprintf(&cases, "\tcase %q:\n\t\tif s.%s != \"\" {\n\t\t\treturn sugar.%s{%q: s.%s}\n\t\t}\n", name, field, param, s.Name, field)
+		}
+	}
+
+	fmt.Fprintf(&g.types, "// Security keeps credentials of security schemes.\ntype Security struct {\n%s}\n\n", fields.String())
+	fmt.Fprintf(&g.types, "// param returns the param carrying the credential of a scheme, or nil if the credential is not set.\nfunc (s *Security) param(scheme string) interface{} {\n\tswitch scheme {\n%s\t}\n\treturn nil\n}\n\n", cases.String())
+	g.types.WriteString(securityParams)
+}
+
+// securityParams applies the first alternative security requirement whose credentials are all set,
+// because OpenAPI treats alternative requirements as OR and schemes of a requirement as AND.
+const securityParams = `// params returns params of the first requirement whose credentials are all set.
+func (s *Security) params(requirements ...[]string) []interface{} {
+	for _, schemes := range requirements {
+		var params []interface{}
+		for _, scheme := range schemes {
+			if p := s.param(scheme); p != nil {
+				params = append(params, p)
+			}
+		}
+		if len(params) == len(schemes) {
+			return params
+		}
+	}
+	return nil
+}
+
+`
+
+func (g *generator) operation(path, method string, item *pathItem, op *operation) error {
+	name := exportName(op.OperationID)
+	if name == "" {
+		name = exportName(strings.ToLower(method) + " " + path)
+	}
+	name = unique(g.methods, name)
+
+	g.use("context", "net/http", sugarPackage)
+	if !g.client {
+		g.client = true
+		g.use("strings")
+		g.types.WriteString("// Client sends requests via a sugar client.\ntype Client struct {\n\tclient   *sugar.Client\n\tbaseUrl  string\n\tsecurity Security\n}\n\n")
+		g.types.WriteString("// NewClient returns a Client which sends requests to baseUrl.\n")
+		g.types.WriteString("func NewClient(client *sugar.Client, baseUrl string, security Security) *Client {\n\treturn &Client{client: client, baseUrl: strings.TrimRight(baseUrl, \"/\"), security: security}\n}\n\n")
+		if len(g.doc.Components.SecuritySchemes) == 0 {
+			g.types.WriteString("// Security is empty since the document declares no security schemes.\ntype Security struct{}\n\nfunc (s *Security) params(requirements ...[]string) []interface{} {\n\treturn nil\n}\n\n")
+		}
+	}
+
+	var args []string
+	var params []string
+	paramsType, err := g.parameters(name, append(append([]*parameter{}, item.Parameters...), op.Parameters...))
+	if err != nil {
+		return err
+	}
+	if paramsType != "" {
+		args = append(args, "params *"+paramsType)
+		params = append(params, "\tif params != nil {\n\t\tps = append(ps, sugar.Struct{Payload: params})\n\t}\n")
+	}
+
+	body, err := g.doc.requestBody(op.RequestBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		contentType, media := pickMedia(body.Content)
+		t, err := g.goType(name+"Body", media.Schema)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case strings.Contains(contentType, "json"):
+			params = append(params, "\tps = append(ps, sugar.Json{Payload: body})\n")
+		case strings.Contains(contentType, "xml"):
+			params = append(params, "\tps = append(ps, sugar.Xml{Payload: body})\n")
+		case strings.HasPrefix(contentType, "text/") && t == "string":
+			params = append(params, "\tps = append(ps, body)\n")
+		default:
+			return fmt.Errorf("unsupported request body %q", contentType)
+		}
+		args = append(args, "body "+t)
+	}
+
+	security := g.doc.Security
+	if op.Security != nil {
+		security = *op.Security
+	}
+	var requirements []string
+	var secured bool
+	for _, requirement := range security {
+		var schemes []string
+		for _, scheme := range sortedKeys(requirement) {
+			schemes = append(schemes, fmt.Sprintf("%q", scheme))
+		}
+		requirements = append(requirements, "[]string{"+strings.Join(schemes, ", ")+"}")
+		secured = secured || len(schemes) > 0
+	}
+	if secured {
+		params = append(params, fmt.Sprintf("\tps = append(ps, c.security.params(%s)...)\n", strings.Join(requirements, ", ")))
+	}
+
+	success, content, errorCases, err := g.responses(name, method, path, op.Responses)
+	if err != nil {
+		return err
+	}
+
+	results := "*http.Response, error"
+	zero := ""
+	if success != "" {
+		results = success + ", *http.Response, error"
+		zero = "out, "
+	}
+
+	f := &g.funcs
+	comment := op.Summary
+	if comment == "" {
+		comment = "sends " + method + " " + path + "."
+	}
+	g.comment(f, name, comment)
+	// The body is left open only if it is declared but not decoded, e.g. a file download.
+	if success == "" && content {
+		f.WriteString("// The caller must close the body of the response.\n")
+	}
+	fmt.Fprintf(f, "func (c *Client) %s(%s) (%s) {\n", name, strings.Join(append([]string{"ctx context.Context"}, args...), ", "), results)
+	if success != "" {
+		fmt.Fprintf(f, "\tvar out %s\n", success)
+	}
+	f.WriteString("\tvar ps []interface{}\n")
+	f.WriteString(strings.Join(params, ""))
+	fmt.Fprintf(f, "\tr := c.client.Do(ctx, %q, c.baseUrl+%q, ps...)\n", method, sugarPath(path))
+	fmt.Fprintf(f, "\tresp, err := r.Raw()\n\tif err != nil {\n\t\treturn %sresp, err\n\t}\n\n", zero)
+	f.WriteString(errorCases)
+	if success != "" {
+		f.WriteString("\t_, err = r.Read(&out)\n\treturn out, resp, err\n}\n\n")
+	} else if content {
+		f.WriteString("\treturn resp, nil\n}\n\n")
+	} else {
+		f.WriteString("\tr.Close()\n\treturn resp, nil\n}\n\n")
+	}
+	return nil
+}
+
+// parameters declares a struct with tagged fields of path, query, header and cookie parameters of an operation,
+// and returns the name of the struct, or an empty string if the operation has no parameters.
+func (g *generator) parameters(op string, params []*parameter) (string, error) {
+	name := op + "Params"
+	var fields []string
+	seen := map[string]bool{}
+	for i := len(params) - 1; i >= 0; i-- {
+		p, err := g.doc.parameter(params[i])
+		if err != nil {
+			return "", err
+		}
+
+		key := p.In + ":" + p.Name
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		switch p.In {
+		case "path", "query", "header", "cookie":
+		default:
+			return "", fmt.Errorf("unsupported parameter location %q", p.In)
+		}
+
+		t, err := g.goType(name+exportName(p.Name), p.Schema)
+		if err != nil {
+			return "", err
+		}
+
+		tag := p.Name
+		if !p.Required && p.In != "path" {
+			tag += ",omitempty"
+			if !g.nillable(p.Schema) {
+				t = "*" + t
+			}
+		}
+		fields = append([]string{fmt.Sprintf("\t%s %s `%s:%q`\n", exportName(p.Name), t, p.In, tag)}, fields...)
+	}
+
+	if len(seen) == 0 {
+		return "", nil
+	}
+
+	name = g.declare(name)
+	fmt.Fprintf(&g.types, "// %s keeps parameters of %s.\ntype %s struct {\n%s}\n\n", name, op, name, strings.Join(fields, ""))
+	return name, nil
+}
+
+// responses returns the success type, whether a success response declares content,
+// and a switch which turns other status codes into typed errors.
+func (g *generator) responses(name, method, path string, responses map[string]*response) (string, bool, string, error) {
+	var success string
+	var content bool
+	var cases, fallback bytes.Buffer
+	zero := ""
+
+	codes := sortedKeys(responses)
+	for _, code := range codes {
+		if !strings.HasPrefix(code, "2") {
+			continue
+		}
+
+		r, err := g.doc.response(responses[code])
+		if err != nil {
+			return "", false, "", err
+		}
+		content = content || len(r.Content) > 0
+		if success != "" {
+			continue
+		}
+		if _, media := pickMedia(r.Content); media != nil && media.Schema != nil {
+			if success, err = g.goType(name+"Response", media.Schema); err != nil {
+				return "", false, "", err
+			}
+			zero = "out, "
+		}
+	}
+
+	for _, code := range codes {
+		if strings.HasPrefix(code, "2") {
+			continue
+		}
+
+		r, err := g.doc.response(responses[code])
+		if err != nil {
+			return "", false, "", err
+		}
+
+		errName := g.declare(name + exportName(code) + "Error")
+		var bodyType string
+		if _, media := pickMedia(r.Content); media != nil && media.Schema != nil {
+			if bodyType, err = g.goType(errName+"Body", media.Schema); err != nil {
+				return "", false, "", err
+			}
+		}
+
+		status := "status " + code
+		if code == "default" {
+			status = "an undeclared status"
+		}
+		g.use("fmt")
+		g.comment(&g.types, errName, "is returned when "+method+" "+path+" responds with "+status+".")
+		fmt.Fprintf(&g.types, "type %s struct {\n\tStatusCode int\n", errName)
+		if bodyType != "" {
+			fmt.Fprintf(&g.types, "\tBody %s\n", bodyType)
+		}
+		fmt.Fprintf(&g.types, "}\n\nfunc (e *%s) Error() string {\n\treturn fmt.Sprintf(\"%s %s: status %%d\", e.StatusCode)\n}\n\n", errName, method, path)
+
+		read := "\t\tr.Close()\n"
+		if bodyType != "" {
+			read = fmt.Sprintf("\t\tif _, err := r.Read(&e.Body); err != nil {\n\t\t\treturn %sresp, err\n\t\t}\n", zero)
+		}
+		handle := fmt.Sprintf("\t\te := &%s{StatusCode: resp.StatusCode}\n%s\t\treturn %sresp, e\n", errName, read, zero)
+
+		switch {
+		case code == "default":
+			fmt.Fprintf(&fallback, "\tif resp.StatusCode < 200 || resp.StatusCode > 299 {\n%s\t}\n\n", handle)
+		case len(code) == 3 && strings.HasSuffix(strings.ToUpper(code), "XX"):
+			fmt.Fprintf(&cases, "\tif resp.StatusCode/100 == %s {\n%s\t}\n\n", code[:1], handle)
+		default:
+			fmt.Fprintf(&cases, "\tif resp.StatusCode == %s {\n%s\t}\n\n", code, handle)
+		}
+	}
+
+	return success, content, cases.String() + fallback.String(), nil
+}
+
+func (g *generator) comment(b *bytes.Buffer, name, text string) {
+	if text = oneLine(text); text == "" {
+		return
+	}
+
+	if strings.HasPrefix(text, name+" ") {
+		fmt.Fprintf(b, "// %s\n", text)
+	} else {
+		fmt.Fprintf(b, "// %s %s\n", name, text)
+	}
+}
+
+// pickMedia prefers JSON, then XML, then plain text content.
+func pickMedia(content map[string]*mediaType) (string, *mediaType) {
+	keys := sortedKeys(content)
+	for _, want := range []string{"application/json", "json", "xml", "text/plain"} {
+		for _, k := range keys {
+			if strings.Contains(k, want) {
+				return k, content[k]
+			}
+		}
+	}
+
+	if len(keys) > 0 {
+		return keys[0], content[keys[0]]
+	}
+	return "", nil
+}
+
+// sugarPath converts /books/{id} into /books/:id which is understood by PathEncoder.
+func sugarPath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '{':
+			b.WriteByte(':')
+		case '}':
+		default:
+			b.WriteByte(path[i])
+		}
+	}
+	return b.String()
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+const sugarPackage = "github.com/pojozhang/sugar"
+
+var initialisms = map[string]string{"id": "ID", "url": "URL", "api": "API", "http": "HTTP", "json": "JSON", "xml": "XML", "uuid": "UUID"}
+
+// exportName converts names like get-book_by id into GetBookByID.
+func exportName(s string) string {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var b strings.Builder
+	for _, w := range words {
+		if v, ok := initialisms[strings.ToLower(w)]; ok {
+			b.WriteString(v)
+			continue
+		}
+		b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+
+	name := b.String()
+	if name != "" && unicode.IsDigit(rune(name[0])) {
+		name = "Status" + name
+	}
+	return name
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
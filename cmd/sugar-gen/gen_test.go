@@ -0,0 +1,216 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sourceImporter is shared by tests, so that the sugar package is type checked once.
+var sourceImporter = importer.ForCompiler(token.NewFileSet(), "source", nil)
+
+// typeCheck checks that generated code compiles against the sugar package.
+func typeCheck(t *testing.T, src []byte) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "gen.go", src, 0)
+	if !assert.Nil(t, err) {
+		return
+	}
+	config := &types.Config{Importer: sourceImporter}
+	_, err = config.Check("api", fset, []*ast.File{f}, nil)
+	assert.Nil(t, err, string(src))
+}
+
+func TestGenerate(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/books.yaml")
+	assert.Nil(t, err)
+	doc, err := parseDocument(b)
+	assert.Nil(t, err)
+
+	src, err := generate(doc, "books")
+
+	assert.Nil(t, err)
+	typeCheck(t, src)
+	s := string(src)
+	assert.Contains(t, s, "package books")
+	assert.Contains(t, s, "type Book struct {")
+	assert.Contains(t, s, "Author *BookAuthor `json:\"author,omitempty\"`")
+	assert.Contains(t, s, "ID     int64       `json:\"id\"`")
+	assert.Contains(t, s, "Page *int64   `query:\"page,omitempty\"`")
+	assert.Contains(t, s, "Tag  []string `query:\"tag,omitempty\"`")
+	assert.Contains(t, s, "ID     int64   `path:\"id\"`")
+	assert.Contains(t, s, "XTrace *string `header:\"X-Trace,omitempty\"`")
+	assert.Contains(t, s, "Code    *int32  `json:\"code,omitempty\"`")
+	assert.Contains(t, s, "func (c *Client) ListBooks(ctx context.Context, params *ListBooksParams) ([]Book, *http.Response, error)")
+	assert.Contains(t, s, "func (c *Client) CreateBook(ctx context.Context, body NewBook) (Book, *http.Response, error)")
+	assert.Contains(t, s, "func (c *Client) DeleteBook(ctx context.Context, params *DeleteBookParams) (*http.Response, error)")
+	assert.Contains(t, s, "\tr.Close()\n\treturn resp, nil\n}")
+	assert.Contains(t, s, `r := c.client.Do(ctx, "GET", c.baseUrl+"/books/:id", ps...)`)
+	assert.Contains(t, s, "if resp.StatusCode == 404 {")
+	assert.Contains(t, s, "if resp.StatusCode/100 == 4 {")
+	assert.Contains(t, s, `return sugar.Header{"X-API-Key": s.ApiKey}`)
+	assert.Contains(t, s, `ps = append(ps, c.security.params([]string{"basicAuth"})...)`)
+}
+
+func TestGenerate_Imports_Used_Packages_Only(t *testing.T) {
+	doc, err := parseDocument([]byte(`{
+  "openapi": "3.0.0",
+  "paths": {},
+  "components": {"schemas": {"Book": {"type": "object", "description": "Book is built by fmt.Sprintf and strings.Join.", "properties": {"name": {"type": "string"}}}}}
+}`))
+	assert.Nil(t, err)
+
+	src, err := generate(doc, "books")
+
+	assert.Nil(t, err)
+	assert.NotContains(t, string(src), "import")
+	typeCheck(t, src)
+}
+
+func TestGenerate_Renames_Colliding_Types(t *testing.T) {
+	doc, err := parseDocument([]byte(`{
+  "openapi": "3.0.0",
+  "paths": {"/books": {"get": {
+    "operationId": "listBooks",
+    "parameters": [{"name": "page", "in": "query", "schema": {"type": "integer"}}],
+    "responses": {
+      "200": {"description": "OK", "content": {"application/json": {"schema": {"type": "object", "properties": {"client": {"$ref": "#/components/schemas/Client"}}}}}},
+      "404": {"description": "Not found"}
+    }
+  }}},
+  "components": {"schemas": {
+    "Client": {"type": "object", "properties": {"name": {"type": "string"}}},
+    "Security": {"type": "string"},
+    "ListBooksParams": {"type": "object", "properties": {"page": {"type": "integer"}}},
+    "ListBooksResponse": {"type": "array", "items": {"type": "string"}},
+    "ListBooksStatus404Error": {"type": "string"}
+  }}
+}`))
+	assert.Nil(t, err)
+
+	src, err := generate(doc, "books")
+
+	assert.Nil(t, err)
+	typeCheck(t, src)
+	s := string(src)
+	assert.Contains(t, s, "type ClientSchema struct {")
+	assert.Contains(t, s, "type SecuritySchema string")
+	assert.Contains(t, s, "type ListBooksParams2 struct {")
+	assert.Contains(t, s, "type ListBooksResponse2 struct {")
+	assert.Contains(t, s, "type ListBooksStatus404Error2 struct {")
+	assert.Contains(t, s, "func (c *Client) ListBooks(ctx context.Context, params *ListBooksParams2) (ListBooksResponse2, *http.Response, error)")
+}
+
+func TestGenerate_Applies_Alternative_Security_Requirements(t *testing.T) {
+	doc, err := parseDocument([]byte(`{
+  "openapi": "3.0.0",
+  "security": [{"apiKey": []}, {"bearerAuth": [], "basicAuth": []}, {}],
+  "paths": {"/books": {"get": {"operationId": "listBooks", "responses": {"204": {"description": "OK"}}}}},
+  "components": {"securitySchemes": {
+    "apiKey": {"type": "apiKey", "in": "query", "name": "key"},
+    "basicAuth": {"type": "http", "scheme": "basic"},
+    "bearerAuth": {"type": "http", "scheme": "bearer"}
+  }}
+}`))
+	assert.Nil(t, err)
+
+	src, err := generate(doc, "books")
+
+	assert.Nil(t, err)
+	typeCheck(t, src)
+	assert.Contains(t, string(src), `ps = append(ps, c.security.params([]string{"apiKey"}, []string{"basicAuth", "bearerAuth"}, []string{})...)`)
+}
+
+func TestGenerate_Leaves_Undecoded_Body_Open(t *testing.T) {
+	doc, err := parseDocument([]byte(`{
+  "openapi": "3.0.0",
+  "paths": {"/books/cover": {"get": {
+    "operationId": "downloadCover",
+    "responses": {"200": {"description": "OK", "content": {"image/png": {}}}}
+  }}}
+}`))
+	assert.Nil(t, err)
+
+	src, err := generate(doc, "books")
+
+	assert.Nil(t, err)
+	typeCheck(t, src)
+	s := string(src)
+	assert.Contains(t, s, "// DownloadCover sends GET /books/cover.\n// The caller must close the body of the response.\n")
+	assert.NotContains(t, s, "r.Close()")
+}
+
+func TestGenerate_Uses_Pointers_For_Optional_Objects(t *testing.T) {
+	doc, err := parseDocument([]byte(`{
+  "openapi": "3.0.0",
+  "paths": {},
+  "components": {"schemas": {
+    "Node": {"type": "object", "required": ["value"], "properties": {
+      "value": {"$ref": "#/components/schemas/Value"},
+      "next": {"$ref": "#/components/schemas/Node"},
+      "meta": {"$ref": "#/components/schemas/Meta"},
+      "tags": {"type": "array", "items": {"type": "string"}}
+    }},
+    "Meta": {"$ref": "#/components/schemas/Value"},
+    "Value": {"type": "object", "properties": {"text": {"type": "string"}}}
+  }}
+}`))
+	assert.Nil(t, err)
+
+	src, err := generate(doc, "nodes")
+
+	assert.Nil(t, err)
+	typeCheck(t, src)
+	s := string(src)
+	assert.Contains(t, s, "Meta  *Meta    `json:\"meta,omitempty\"`")
+	assert.Contains(t, s, "Next  *Node    `json:\"next,omitempty\"`")
+	assert.Contains(t, s, "Tags  []string `json:\"tags,omitempty\"`")
+	assert.Contains(t, s, "Value Value    `json:\"value\"`")
+}
+
+func TestGenerate_Returns_Error_If_Schema_Names_Collide(t *testing.T) {
+	doc, err := parseDocument([]byte(`{
+  "openapi": "3.0.0",
+  "paths": {},
+  "components": {"schemas": {"book-id": {"type": "string"}, "BookID": {"type": "integer"}}}
+}`))
+	assert.Nil(t, err)
+
+	_, err = generate(doc, "books")
+
+	assert.EqualError(t, err, "schema book-id: type BookID is already declared by another schema")
+}
+
+func TestParseDocument_Returns_Error_If_Version_Is_Not_Supported(t *testing.T) {
+	_, err := parseDocument([]byte(`{"swagger": "2.0"}`))
+
+	assert.NotNil(t, err)
+}
+
+func TestGenerate_Returns_Error_If_Ref_Is_Missing(t *testing.T) {
+	doc, err := parseDocument([]byte(`{
+  "openapi": "3.0.0",
+  "paths": {"/books/{id}": {"get": {"parameters": [{"$ref": "#/components/parameters/ID"}], "responses": {"204": {"description": "OK"}}}}}
+}`))
+	assert.Nil(t, err)
+
+	_, err = generate(doc, "books")
+
+	assert.NotNil(t, err)
+}
+
+func TestExportName(t *testing.T) {
+	assert.Equal(t, "GetBookByID", exportName("get-book_by id"))
+	assert.Equal(t, "Status404", exportName("404"))
+	assert.Equal(t, "XTrace", exportName("X-Trace"))
+}
+
+func TestSugarPath(t *testing.T) {
+	assert.Equal(t, "/books/:id/authors/:name", sugarPath("/books/{id}/authors/{name}"))
+}
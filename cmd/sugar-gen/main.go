@@ -0,0 +1,59 @@
+// Command sugar-gen generates a typed sugar client from an OpenAPI 3 document.
+//
+//	sugar-gen -spec openapi.yaml -package books -output books_gen.go
+//
+// Component schemas become Go types, and every operation becomes a method of the generated Client.
+// Optional properties of object types are pointers, so that omitempty omits them.
+// Path, query, header and cookie parameters are encoded via a tagged params struct,
+// request bodies via Json{} or Xml{}, and security schemes via User{} or Header{} params.
+// Of alternative security requirements, the first one whose credentials are all set is applied.
+// Non-2xx responses declared in the document are returned as typed errors.
+// Types derived from operations, e.g. ListBooksParams, get a number suffix if a schema has the same name,
+// and schemas called Client, NewClient or Security get a Schema suffix.
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+var (
+	spec   = flag.String("spec", "", "path of the OpenAPI 3 document in YAML or JSON; required")
+	pkg    = flag.String("package", "api", "package name of the generated file")
+	output = flag.String("output", "", "output file name; default stdout")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("sugar-gen: ")
+	flag.Parse()
+	if *spec == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	b, err := ioutil.ReadFile(*spec)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	doc, err := parseDocument(b)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	src, err := generate(doc, *pkg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *output == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := ioutil.WriteFile(*output, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
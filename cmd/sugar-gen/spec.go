@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// document is the subset of an OpenAPI 3 document used by the generator.
+type document struct {
+	OpenAPI    string                `yaml:"openapi"`
+	Paths      map[string]*pathItem  `yaml:"paths"`
+	Components components            `yaml:"components"`
+	Security   []map[string][]string `yaml:"security"`
+}
+
+type components struct {
+	Schemas         map[string]*schema         `yaml:"schemas"`
+	Parameters      map[string]*parameter      `yaml:"parameters"`
+	RequestBodies   map[string]*requestBody    `yaml:"requestBodies"`
+	Responses       map[string]*response       `yaml:"responses"`
+	SecuritySchemes map[string]*securityScheme `yaml:"securitySchemes"`
+}
+
+type pathItem struct {
+	Parameters []*parameter `yaml:"parameters"`
+	Get        *operation   `yaml:"get"`
+	Put        *operation   `yaml:"put"`
+	Post       *operation   `yaml:"post"`
+	Delete     *operation   `yaml:"delete"`
+	Options    *operation   `yaml:"options"`
+	Head       *operation   `yaml:"head"`
+	Patch      *operation   `yaml:"patch"`
+}
+
+func (p *pathItem) operations() []struct {
+	method string
+	op     *operation
+} {
+	var ops []struct {
+		method string
+		op     *operation
+	}
+	for _, o := range []struct {
+		method string
+		op     *operation
+	}{
+		{"GET", p.Get}, {"PUT", p.Put}, {"POST", p.Post}, {"DELETE", p.Delete},
+		{"OPTIONS", p.Options}, {"HEAD", p.Head}, {"PATCH", p.Patch},
+	} {
+		if o.op != nil {
+			ops = append(ops, o)
+		}
+	}
+	return ops
+}
+
+type operation struct {
+	OperationID string                 `yaml:"operationId"`
+	Summary     string                 `yaml:"summary"`
+	Parameters  []*parameter           `yaml:"parameters"`
+	RequestBody *requestBody           `yaml:"requestBody"`
+	Responses   map[string]*response   `yaml:"responses"`
+	Security    *[]map[string][]string `yaml:"security"`
+}
+
+type parameter struct {
+	Ref      string  `yaml:"$ref"`
+	Name     string  `yaml:"name"`
+	In       string  `yaml:"in"`
+	Required bool    `yaml:"required"`
+	Schema   *schema `yaml:"schema"`
+}
+
+type requestBody struct {
+	Ref      string                `yaml:"$ref"`
+	Required bool                  `yaml:"required"`
+	Content  map[string]*mediaType `yaml:"content"`
+}
+
+type response struct {
+	Ref         string                `yaml:"$ref"`
+	Description string                `yaml:"description"`
+	Content     map[string]*mediaType `yaml:"content"`
+}
+
+type mediaType struct {
+	Schema *schema `yaml:"schema"`
+}
+
+type schema struct {
+	Ref         string             `yaml:"$ref"`
+	Type        string             `yaml:"type"`
+	Format      string             `yaml:"format"`
+	Properties  map[string]*schema `yaml:"properties"`
+	Required    []string           `yaml:"required"`
+	Items       *schema            `yaml:"items"`
+	AllOf       []*schema          `yaml:"allOf"`
+	OneOf       []*schema          `yaml:"oneOf"`
+	AnyOf       []*schema          `yaml:"anyOf"`
+	Description string             `yaml:"description"`
+}
+
+type securityScheme struct {
+	Type   string `yaml:"type"`
+	Scheme string `yaml:"scheme"`
+	In     string `yaml:"in"`
+	Name   string `yaml:"name"`
+}
+
+// parseDocument parses a YAML or JSON OpenAPI 3 document.
+func parseDocument(b []byte) (*document, error) {
+	doc := &document{}
+	if err := yaml.Unmarshal(b, doc); err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported openapi version %q", doc.OpenAPI)
+	}
+	return doc, nil
+}
+
+func refName(ref, prefix string) (string, error) {
+	if !strings.HasPrefix(ref, prefix) {
+		return "", fmt.Errorf("unsupported $ref %q", ref)
+	}
+	return strings.TrimPrefix(ref, prefix), nil
+}
+
+func (d *document) parameter(p *parameter) (*parameter, error) {
+	if p.Ref == "" {
+		return p, nil
+	}
+
+	name, err := refName(p.Ref, "#/components/parameters/")
+	if err != nil {
+		return nil, err
+	}
+	if r, ok := d.Components.Parameters[name]; ok {
+		return d.parameter(r)
+	}
+	return nil, fmt.Errorf("parameter %q not found", p.Ref)
+}
+
+func (d *document) requestBody(b *requestBody) (*requestBody, error) {
+	if b == nil || b.Ref == "" {
+		return b, nil
+	}
+
+	name, err := refName(b.Ref, "#/components/requestBodies/")
+	if err != nil {
+		return nil, err
+	}
+	if r, ok := d.Components.RequestBodies[name]; ok {
+		return d.requestBody(r)
+	}
+	return nil, fmt.Errorf("request body %q not found", b.Ref)
+}
+
+func (d *document) response(r *response) (*response, error) {
+	if r.Ref == "" {
+		return r, nil
+	}
+
+	name, err := refName(r.Ref, "#/components/responses/")
+	if err != nil {
+		return nil, err
+	}
+	if resp, ok := d.Components.Responses[name]; ok {
+		return d.response(resp)
+	}
+	return nil, fmt.Errorf("response %q not found", r.Ref)
+}
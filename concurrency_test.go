@@ -0,0 +1,82 @@
+package sugar
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func echoClient() *Client {
+	return New(func() Transporter {
+		return HandlerTransporter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.Header.Get("X-Request")))
+		}))
+	})
+}
+
+func TestClient_ConcurrentDoAndConfigure(t *testing.T) {
+	client := echoClient()
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 20; i++ {
+		wg.Add(4)
+		go func(i int) {
+			defer wg.Done()
+			b, _, err := client.Get(context.Background(), "http://api.example.com/books", Header{"X-Request": strconv.Itoa(i)}).ReadBytes()
+			assert.Nil(t, err)
+			assert.Equal(t, strconv.Itoa(i), string(b))
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			client.Apply(Query{"page": i})
+		}(i)
+		go func() {
+			defer wg.Done()
+			client.Use(func(c *Context) error { return c.Next() })
+			client.UsePlugin(PluginFunc(func(c *Context) error { return c.Next() }))
+		}()
+		go func() {
+			defer wg.Done()
+			client.Reset()
+			client.Clone()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestClient_ConcurrentRequestsDoNotSharePresets(t *testing.T) {
+	client := echoClient()
+	// Spare capacity would let appends of concurrent requests write into the same backing array.
+	client.Presets = append(make([]interface{}, 0, 8), Query{"lang": "en"})
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			b, _, err := client.Get(context.Background(), "http://api.example.com/books", Header{"X-Request": strconv.Itoa(i)}).ReadBytes()
+			assert.Nil(t, err)
+			assert.Equal(t, strconv.Itoa(i), string(b))
+		}(i)
+	}
+	wg.Wait()
+	assert.Len(t, client.Presets, 1)
+}
+
+func TestClient_PluginsAddedDuringRequest(t *testing.T) {
+	client := echoClient()
+	plugin := &mockPlugin{}
+	client.Use(func(c *Context) error {
+		client.UsePlugin(plugin)
+		return c.Next()
+	})
+
+	client.Get(context.Background(), "http://api.example.com/books")
+	assert.Equal(t, 0, plugin.count)
+	client.Get(context.Background(), "http://api.example.com/books")
+	assert.Equal(t, 1, plugin.count)
+}
@@ -0,0 +1,473 @@
+package sugar
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config describes a Client. It is loaded from YAML or JSON documents by LoadConfig.
+type Config struct {
+	BaseUrl   string            `yaml:"base_url"`
+	Timeout   time.Duration     `yaml:"timeout"`
+	UserAgent string            `yaml:"user_agent"`
+	Headers   map[string]string `yaml:"headers"`
+	Transport TransportConfig   `yaml:"transport"`
+	Plugins   PluginsConfig     `yaml:"plugins"`
+	Presets   PresetsConfig     `yaml:"presets"`
+}
+
+// TransportConfig configures the http.Client and http.Transport.
+type TransportConfig struct {
+	Proxy                 string        `yaml:"proxy"`
+	CookieJar             bool          `yaml:"cookie_jar"`
+	MaxIdleConns          int           `yaml:"max_idle_conns"`
+	MaxIdleConnsPerHost   int           `yaml:"max_idle_conns_per_host"`
+	MaxConnsPerHost       int           `yaml:"max_conns_per_host"`
+	IdleConnTimeout       time.Duration `yaml:"idle_conn_timeout"`
+	TLSHandshakeTimeout   time.Duration `yaml:"tls_handshake_timeout"`
+	ResponseHeaderTimeout time.Duration `yaml:"response_header_timeout"`
+	DisableKeepAlives     bool          `yaml:"disable_keep_alives"`
+	TLS                   *TLSConfig    `yaml:"tls"`
+}
+
+// TLSConfig configures TLS connections.
+type TLSConfig struct {
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	// MinVersion is one of "1.0", "1.1", "1.2" and "1.3".
+	MinVersion string `yaml:"min_version"`
+}
+
+// PluginsConfig configures builtin plugins. They are used in the order of logger, retry and rate limit.
+type PluginsConfig struct {
+	Logger    *LoggerConfig    `yaml:"logger"`
+	Retry     *RetryConfig     `yaml:"retry"`
+	RateLimit *RateLimitConfig `yaml:"rate_limit"`
+}
+
+// LoggerConfig configures the Logging plugin.
+type LoggerConfig struct {
+	// Level is one of "debug", "info", "warn" and "error", default "info".
+	Level         string   `yaml:"level"`
+	OnlyFailures  bool     `yaml:"only_failures"`
+	MaxBodySize   int      `yaml:"max_body_size"`
+	RedactHeaders []string `yaml:"redact_headers"`
+	RedactQuery   []string `yaml:"redact_query"`
+	RedactFields  []string `yaml:"redact_fields"`
+}
+
+// RetryConfig configures the RetryPolicy plugin.
+type RetryConfig struct {
+	Attempts           int           `yaml:"attempts"`
+	Delay              time.Duration `yaml:"delay"`
+	Multiplier         float64       `yaml:"multiplier"`
+	MaxDelay           time.Duration `yaml:"max_delay"`
+	AttemptTimeout     time.Duration `yaml:"attempt_timeout"`
+	RetryNonIdempotent bool          `yaml:"retry_non_idempotent"`
+	// Jitter is one of "none", "full", "equal" and "decorrelated", default "none".
+	Jitter string `yaml:"jitter"`
+}
+
+// RateLimitConfig configures the RateLimiter plugin.
+type RateLimitConfig struct {
+	Rate     float64 `yaml:"rate"`
+	Burst    int     `yaml:"burst"`
+	FailFast bool    `yaml:"fail_fast"`
+	Adaptive bool    `yaml:"adaptive"`
+}
+
+// PresetsConfig configures params attached to every request.
+type PresetsConfig struct {
+	Header map[string]string `yaml:"header"`
+	Query  map[string]string `yaml:"query"`
+	User   *UserConfig       `yaml:"user"`
+}
+
+// UserConfig is the credential of basic authentication.
+type UserConfig struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// ConfigError points at the key of a config document which is invalid.
+type ConfigError struct {
+	Key  string
+	Line int
+	Err  error
+}
+
+func (e *ConfigError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("config: %s (line %d): %v", e.Key, e.Line, e.Err)
+	}
+	return fmt.Sprintf("config: %s: %v", e.Key, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// LoadConfigFile reads a YAML or JSON config file and builds a Client. See LoadConfig.
+func LoadConfigFile(path string, options ...Option) (*Client, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return LoadConfig(b, options...)
+}
+
+// LoadConfig builds a Client from a YAML or JSON config document. Options are applied after the config.
+// Values may refer to environment variables as ${NAME}, or ${NAME:-default} if the variable may be unset.
+func LoadConfig(data []byte, options ...Option) (*Client, error) {
+	config, err := ParseConfig(data)
+	if err != nil {
+		return nil, err
+	}
+
+	configOptions, err := config.Options()
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(append(configOptions, options...)...), nil
+}
+
+// ParseConfig parses a YAML or JSON config document, interpolates environment variables and checks unknown keys.
+func ParseConfig(data []byte) (*Config, error) {
+	root := &yaml.Node{}
+	if err := yaml.Unmarshal(data, root); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	config := &Config{}
+	if len(root.Content) == 0 {
+		return config, nil
+	}
+
+	p := &configParser{lines: map[string]int{}}
+	if err := p.check(root.Content[0], reflect.TypeOf(config).Elem(), ""); err != nil {
+		return nil, err
+	}
+	if err := root.Content[0].Decode(config); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	if err := config.validate(p.lines); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+type configParser struct {
+	// lines keeps the line of every key, so that validation errors can point at them.
+	lines map[string]int
+}
+
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// check interpolates environment variables in scalars, and reports unknown keys and values of wrong types.
+func (p *configParser) check(node *yaml.Node, t reflect.Type, path string) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch node.Kind {
+	case yaml.AliasNode:
+		return p.check(node.Alias, t, path)
+	case yaml.ScalarNode:
+		if err := p.interpolate(node, t, path); err != nil {
+			return err
+		}
+		if node.Tag == "!!null" {
+			return nil
+		}
+		if err := node.Decode(reflect.New(t).Interface()); err != nil {
+			return &ConfigError{Key: path, Line: node.Line, Err: fmt.Errorf("expected %s, got %q", typeName(t), node.Value)}
+		}
+		return nil
+	case yaml.SequenceNode:
+		if t.Kind() != reflect.Slice {
+			return &ConfigError{Key: path, Line: node.Line, Err: fmt.Errorf("expected %s, got a list", typeName(t))}
+		}
+		for i, item := range node.Content {
+			if err := p.check(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case yaml.MappingNode:
+		if t.Kind() != reflect.Struct && t.Kind() != reflect.Map {
+			return &ConfigError{Key: path, Line: node.Line, Err: fmt.Errorf("expected %s, got a map", typeName(t))}
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			keyPath := key.Value
+			if path != "" {
+				keyPath = path + "." + key.Value
+			}
+			p.lines[keyPath] = key.Line
+
+			valueType := t
+			if t.Kind() == reflect.Map {
+				valueType = t.Elem()
+			} else if field, ok := yamlField(t, key.Value); ok {
+				valueType = field.Type
+			} else {
+				return &ConfigError{Key: keyPath, Line: key.Line, Err: fmt.Errorf("unknown key")}
+			}
+			if err := p.check(value, valueType, keyPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *configParser) interpolate(node *yaml.Node, t reflect.Type, path string) error {
+	var err error
+	value := envPattern.ReplaceAllStringFunc(node.Value, func(s string) string {
+		m := envPattern.FindStringSubmatch(s)
+		if v, ok := os.LookupEnv(m[1]); ok {
+			return v
+		}
+		if m[2] != "" {
+			return m[3]
+		}
+		err = &ConfigError{Key: path, Line: node.Line, Err: fmt.Errorf("environment variable %s is not set", m[1])}
+		return s
+	})
+	if err != nil {
+		return err
+	}
+
+	if value != node.Value {
+		node.Value = value
+		// The type of an interpolated value is resolved again, e.g. "${PORT}" becomes an int, unless a string is expected.
+		node.Tag, node.Style = "", 0
+		if t.Kind() == reflect.String || value == "" {
+			node.Tag = "!!str"
+		}
+	}
+	return nil
+}
+
+func yamlField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if strings.Split(field.Tag.Get("yaml"), ",")[0] == key {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func typeName(t reflect.Type) string {
+	switch {
+	case t == reflect.TypeOf(time.Duration(0)):
+		return "a duration such as 1s"
+	case t.Kind() == reflect.Bool:
+		return "a boolean"
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return "an integer"
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return "a number"
+	case t.Kind() == reflect.String:
+		return "a string"
+	case t.Kind() == reflect.Slice:
+		return "a list"
+	}
+	return "a map"
+}
+
+func (c *Config) validate(lines map[string]int) error {
+	invalid := func(key, format string, args ...interface{}) error {
+		// A missing key points at the closest parent.
+		line := 0
+		for k := key; line == 0 && k != ""; k = k[:max(strings.LastIndex(k, "."), 0)] {
+			line = lines[k]
+		}
+		return &ConfigError{Key: key, Line: line, Err: fmt.Errorf(format, args...)}
+	}
+
+	if c.BaseUrl != "" {
+		if u, err := url.Parse(c.BaseUrl); err != nil || u.Scheme == "" || u.Host == "" {
+			return invalid("base_url", "expected an absolute url, got %q", c.BaseUrl)
+		}
+	}
+	if c.Timeout < 0 {
+		return invalid("timeout", "must not be negative")
+	}
+	if c.Transport.Proxy != "" {
+		if u, err := url.Parse(c.Transport.Proxy); err != nil || u.Host == "" {
+			return invalid("transport.proxy", "expected a url, got %q", c.Transport.Proxy)
+		}
+	}
+	if c.Transport.TLS != nil && c.Transport.TLS.MinVersion != "" {
+		if _, ok := tlsVersions[c.Transport.TLS.MinVersion]; !ok {
+			return invalid("transport.tls.min_version", "expected one of 1.0, 1.1, 1.2 and 1.3, got %q", c.Transport.TLS.MinVersion)
+		}
+	}
+	if l := c.Plugins.Logger; l != nil && l.Level != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(l.Level)); err != nil {
+			return invalid("plugins.logger.level", "expected one of debug, info, warn and error, got %q", l.Level)
+		}
+	}
+	if r := c.Plugins.Retry; r != nil {
+		if r.Attempts < 1 {
+			return invalid("plugins.retry.attempts", "must be at least 1")
+		}
+		if r.Delay < 0 {
+			return invalid("plugins.retry.delay", "must not be negative")
+		}
+		if _, ok := jitters[r.Jitter]; !ok {
+			return invalid("plugins.retry.jitter", "expected one of none, full, equal and decorrelated, got %q", r.Jitter)
+		}
+	}
+	if r := c.Plugins.RateLimit; r != nil && r.Rate <= 0 {
+		return invalid("plugins.rate_limit.rate", "must be positive")
+	}
+	if u := c.Presets.User; u != nil && u.Username == "" {
+		return invalid("presets.user.username", "must not be empty")
+	}
+	return nil
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var jitters = map[string]Jitter{
+	"":             NoJitter,
+	"none":         NoJitter,
+	"full":         FullJitter,
+	"equal":        EqualJitter,
+	"decorrelated": DecorrelatedJitter,
+}
+
+// Options converts the config into options of NewClient.
+func (c *Config) Options() ([]Option, error) {
+	options := []Option{
+		WithBaseUrl(c.BaseUrl),
+		WithTimeout(c.Timeout),
+		WithUserAgent(c.UserAgent),
+		c.Transport.option(),
+	}
+	for name, value := range c.Headers {
+		options = append(options, WithHeader(name, value))
+	}
+
+	if c.Transport.CookieJar {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, WithCookieJar(jar))
+	}
+
+	if l := c.Plugins.Logger; l != nil {
+		logging := Logging{
+			OnlyFailures:  l.OnlyFailures,
+			MaxBodySize:   l.MaxBodySize,
+			RedactHeaders: l.RedactHeaders,
+			RedactQuery:   l.RedactQuery,
+			RedactFields:  l.RedactFields,
+		}
+		if l.Level != "" {
+			var level slog.Level
+			level.UnmarshalText([]byte(l.Level))
+			logging.Level = level
+		}
+		options = append(options, WithPlugins(logging))
+	}
+	if r := c.Plugins.Retry; r != nil {
+		options = append(options, WithPlugins(RetryPolicy{
+			Attempts:           r.Attempts,
+			Delay:              r.Delay,
+			Multiplier:         r.Multiplier,
+			MaxDelay:           r.MaxDelay,
+			AttemptTimeout:     r.AttemptTimeout,
+			RetryNonIdempotent: r.RetryNonIdempotent,
+			Jitter:             jitters[r.Jitter],
+		}))
+	}
+	if r := c.Plugins.RateLimit; r != nil {
+		options = append(options, WithPlugins(&RateLimiter{Rate: r.Rate, Burst: r.Burst, FailFast: r.FailFast, Adaptive: r.Adaptive}))
+	}
+
+	if len(c.Presets.Header) > 0 {
+		header := Header{}
+		for k, v := range c.Presets.Header {
+			header[k] = v
+		}
+		options = append(options, WithPresets(header))
+	}
+	if len(c.Presets.Query) > 0 {
+		query := Query{}
+		for k, v := range c.Presets.Query {
+			query[k] = v
+		}
+		options = append(options, WithPresets(query))
+	}
+	if u := c.Presets.User; u != nil {
+		options = append(options, WithPresets(User{Name: u.Username, Password: u.Password}))
+	}
+	return options, nil
+}
+
+// option configures the http.Transport. Settings of the zero value are left as http.DefaultTransport has them.
+func (t TransportConfig) option() Option {
+	return func(c *Client) {
+		if reflect.DeepEqual(t, TransportConfig{CookieJar: t.CookieJar}) {
+			return
+		}
+
+		transport := httpTransport(c)
+		if transport == nil {
+			return
+		}
+		if t.Proxy != "" {
+			u, _ := url.Parse(t.Proxy)
+			transport.Proxy = http.ProxyURL(u)
+		}
+		if t.MaxIdleConns > 0 {
+			transport.MaxIdleConns = t.MaxIdleConns
+		}
+		if t.MaxIdleConnsPerHost > 0 {
+			transport.MaxIdleConnsPerHost = t.MaxIdleConnsPerHost
+		}
+		if t.MaxConnsPerHost > 0 {
+			transport.MaxConnsPerHost = t.MaxConnsPerHost
+		}
+		if t.IdleConnTimeout > 0 {
+			transport.IdleConnTimeout = t.IdleConnTimeout
+		}
+		if t.TLSHandshakeTimeout > 0 {
+			transport.TLSHandshakeTimeout = t.TLSHandshakeTimeout
+		}
+		if t.ResponseHeaderTimeout > 0 {
+			transport.ResponseHeaderTimeout = t.ResponseHeaderTimeout
+		}
+		transport.DisableKeepAlives = t.DisableKeepAlives
+		if t.TLS != nil {
+			transport.TLSClientConfig = &tls.Config{
+				ServerName:         t.TLS.ServerName,
+				InsecureSkipVerify: t.TLS.InsecureSkipVerify,
+				MinVersion:         tlsVersions[t.TLS.MinVersion],
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package sugar
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("SUGAR_TEST_PASSWORD", "123456")
+	t.Setenv("SUGAR_TEST_ATTEMPTS", "3")
+
+	client, err := LoadConfig([]byte(`
+base_url: https://api.example.com/v1
+timeout: 5s
+user_agent: books/1.0
+headers:
+  Accept: application/json
+transport:
+  proxy: http://proxy.example.com:8080
+  max_idle_conns_per_host: 10
+  tls:
+    server_name: api.example.com
+    min_version: "1.2"
+plugins:
+  logger:
+    level: warn
+    only_failures: true
+  retry:
+    attempts: ${SUGAR_TEST_ATTEMPTS}
+    delay: 100ms
+    jitter: full
+  rate_limit:
+    rate: 10
+    burst: 5
+presets:
+  query:
+    lang: ${SUGAR_TEST_LANG:-en}
+  user:
+    username: admin
+    password: ${SUGAR_TEST_PASSWORD}
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "https://api.example.com/v1", client.BaseUrl)
+	assert.Equal(t, 5*time.Second, client.Timeout)
+	assert.Equal(t, "books/1.0", client.UserAgent)
+	assert.Equal(t, "application/json", client.Header.Get("Accept"))
+	assert.Equal(t, []interface{}{Query{"lang": "en"}, User{"admin", "123456"}}, client.Presets)
+
+	if assert.Len(t, client.Plugins, 3) {
+		assert.Equal(t, true, client.Plugins[0].(Logging).OnlyFailures)
+		assert.Equal(t, RetryPolicy{Attempts: 3, Delay: 100 * time.Millisecond, Jitter: FullJitter}, client.Plugins[1])
+		assert.Equal(t, 10.0, client.Plugins[2].(*RateLimiter).Rate)
+	}
+
+	transport := client.Transporter.(*http.Client).Transport.(*http.Transport)
+	assert.Equal(t, 10, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, "api.example.com", transport.TLSClientConfig.ServerName)
+	assert.Equal(t, uint16(tls.VersionTLS12), transport.TLSClientConfig.MinVersion)
+	proxy, err := transport.Proxy(mustRequest(http.MethodGet, "https://api.example.com", nil))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "proxy.example.com:8080", proxy.Host)
+	}
+}
+
+func TestLoadConfigFile_Json(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "client.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"base_url": "https://api.example.com", "presets": {"header": {"X-Tenant": "a"}}}`), 0644))
+
+	client, err := LoadConfigFile(path, WithUserAgent("books/2.0"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "https://api.example.com", client.BaseUrl)
+		assert.Equal(t, "books/2.0", client.UserAgent)
+		assert.Equal(t, []interface{}{Header{"X-Tenant": "a"}}, client.Presets)
+	}
+}
+
+func TestLoadConfig_InterpolatedStringsStayStrings(t *testing.T) {
+	t.Setenv("SUGAR_TEST_PASSWORD", "true")
+
+	config, err := ParseConfig([]byte("presets:\n  user:\n    username: admin\n    password: ${SUGAR_TEST_PASSWORD}\n"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "true", config.Presets.User.Password)
+	}
+}
+
+func TestParseConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		error  string
+	}{
+		{"unknown key", "transport:\n  proxies: http://proxy\n", "config: transport.proxies (line 2): unknown key"},
+		{"wrong type", "plugins:\n  retry:\n    attempts: many\n", `config: plugins.retry.attempts (line 3): expected an integer, got "many"`},
+		{"bad duration", "timeout: soon\n", `config: timeout (line 1): expected a duration such as 1s, got "soon"`},
+		{"missing env", "base_url: ${SUGAR_TEST_UNSET}\n", "config: base_url (line 1): environment variable SUGAR_TEST_UNSET is not set"},
+		{"relative base url", "base_url: /v1\n", `config: base_url (line 1): expected an absolute url, got "/v1"`},
+		{"attempts", "plugins:\n  retry:\n    delay: 1s\n", "config: plugins.retry.attempts (line 2): must be at least 1"},
+		{"jitter", "plugins:\n  retry:\n    attempts: 2\n    jitter: some\n", `config: plugins.retry.jitter (line 4): expected one of none, full, equal and decorrelated, got "some"`},
+		{"level", "plugins:\n  logger:\n    level: loud\n", `config: plugins.logger.level (line 3): expected one of debug, info, warn and error, got "loud"`},
+		{"tls version", "transport:\n  tls:\n    min_version: 1.4\n", `config: transport.tls.min_version (line 3): expected one of 1.0, 1.1, 1.2 and 1.3, got "1.4"`},
+		{"list", "headers: [a, b]\n", "config: headers (line 1): expected a map, got a list"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseConfig([]byte(test.config))
+			if assert.Error(t, err) {
+				assert.Equal(t, test.error, err.Error())
+				var configError *ConfigError
+				assert.True(t, errors.As(err, &configError))
+			}
+		})
+	}
+}
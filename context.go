@@ -14,11 +14,15 @@ type Context struct {
 	Method      string
 	RawUrl      string
 	params      []interface{}
+	header      http.Header
 	plugins     []Plugin
 	index       int
 	Encoders    EncoderGroup
 	Decoders    DecoderGroup
 	transporter Transporter
+	// statusPolicy and errorBody turn failed responses into *HTTPError.
+	statusPolicy StatusPolicy
+	errorBody    interface{}
 }
 
 // BuildRequest initializes a new request and encodes params via encoders.
@@ -29,12 +33,24 @@ func (c *Context) BuildRequest() (*http.Request, error) {
 	}
 
 	for i, param := range c.params {
+		// ErrorBody is not encoded into the request, and the last one wins.
+		if errorBody, ok := param.(ErrorBody); ok {
+			c.errorBody = errorBody.Value
+			continue
+		}
 		chain := NewEncoderChain(&RequestContext{Request: req, Params: c.params, Param: param, ParamIndex: i}, c.Encoders...)
 		if err := chain.Next(); err != nil {
 			return nil, err
 		}
 	}
 
+	// Default headers of the client are overridden by the ones set by presets and params.
+	for k, v := range c.header {
+		if k = http.CanonicalHeaderKey(k); req.Header[k] == nil {
+			req.Header[k] = append([]string(nil), v...)
+		}
+	}
+
 	return req, nil
 }
 
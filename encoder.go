@@ -2,8 +2,10 @@ package sugar
 
 import (
 	"bytes"
+	"encoding"
 	"encoding/json"
 	"encoding/xml"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"mime/multipart"
@@ -88,6 +90,15 @@ type MultiPart Map
 // MP is an alias for MultiPart.
 type MP = MultiPart
 
+// Struct wraps a tagged struct whose fields are routed to other encoders.
+// Supported tags are path, query, header, cookie, form and json.
+type Struct struct {
+	Payload interface{}
+}
+
+// S is an alias for Struct.
+type S = Struct
+
 // RequestContext keeps values for an encoder.
 type RequestContext struct {
 	Request    *http.Request
@@ -242,6 +253,7 @@ func (e *FormEncoder) Encode(context *RequestContext, chain *EncoderChain) error
 	if err != nil {
 		return err
 	}
+	setBody(req, []byte(form.Encode()))
 
 	if _, ok := req.Header[ContentType]; !ok {
 		req.Header.Set(ContentType, ContentTypeForm)
@@ -277,7 +289,7 @@ func (e *JsonEncoder) Encode(context *RequestContext, chain *EncoderChain) error
 	}
 
 	req := context.Request
-	req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	setBody(req, b)
 
 	if _, ok := req.Header[ContentType]; !ok {
 		req.Header.Set(ContentType, ContentTypeJsonUtf8)
@@ -330,7 +342,6 @@ func (e *MultiPartEncoder) Encode(context *RequestContext, chain *EncoderChain)
 
 	b := &bytes.Buffer{}
 	w := multipart.NewWriter(b)
-	defer w.Close()
 
 	for k, v := range multiPartParams {
 		switch x := v.(type) {
@@ -345,8 +356,12 @@ func (e *MultiPartEncoder) Encode(context *RequestContext, chain *EncoderChain)
 		}
 	}
 
+	if err := w.Close(); err != nil {
+		return err
+	}
+
 	req := context.Request
-	req.Body = ioutil.NopCloser(b)
+	setBody(req, b.Bytes())
 
 	if _, ok := req.Header[ContentType]; !ok {
 		req.Header.Set(ContentType, w.FormDataContentType())
@@ -354,6 +369,15 @@ func (e *MultiPartEncoder) Encode(context *RequestContext, chain *EncoderChain)
 	return nil
 }
 
+// setBody sets a replayable body so that the request can be sent again, e.g. by Retryer.
+func setBody(req *http.Request, b []byte) {
+	req.ContentLength = int64(len(b))
+	req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+}
+
 func writeFile(w *multipart.Writer, fieldName, fileName string, file io.Reader) error {
 	fileWriter, err := w.CreateFormFile(fieldName, fileName)
 	if err != nil {
@@ -378,10 +402,8 @@ func (e *PlainTextEncoder) Encode(context *RequestContext, chain *EncoderChain)
 		return chain.Next()
 	}
 
-	b := &bytes.Buffer{}
-	b.WriteString(textParams)
 	req := context.Request
-	req.Body = ioutil.NopCloser(b)
+	setBody(req, []byte(textParams))
 
 	if _, ok := req.Header[ContentType]; !ok {
 		req.Header.Set(ContentType, ContentTypePlainText)
@@ -414,7 +436,7 @@ func (e *XmlEncoder) Encode(context *RequestContext, chain *EncoderChain) error
 	}
 
 	req := context.Request
-	req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	setBody(req, b)
 
 	if _, ok := req.Header[ContentType]; !ok {
 		req.Header.Set(ContentType, ContentTypeXmlUtf8)
@@ -422,6 +444,210 @@ func (e *XmlEncoder) Encode(context *RequestContext, chain *EncoderChain) error
 	return nil
 }
 
+// StructEncoder encodes Struct{} params.
+type StructEncoder struct {
+}
+
+// Encode splits a tagged struct into Path{}, Query{}, Header{}, Cookie{}, Form{} and Json{} params,
+// and then encodes each of them via the encoders of current chain.
+func (e *StructEncoder) Encode(context *RequestContext, chain *EncoderChain) error {
+	structParams, ok := context.Param.(Struct)
+	if !ok {
+		return chain.Next()
+	}
+
+	v := reflect.ValueOf(structParams.Payload)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("sugar: Struct payload must be a struct, got %T", structParams.Payload)
+	}
+
+	fields := &structFields{
+		path:   Path{},
+		query:  Query{},
+		header: Header{},
+		cookie: Cookie{},
+		form:   Form{},
+		json:   Map{},
+	}
+	fields.collect(v)
+
+	for _, param := range fields.params() {
+		c := &RequestContext{Request: context.Request, Params: context.Params, Param: param, ParamIndex: context.ParamIndex}
+		if err := NewEncoderChain(c, chain.encoders...).Next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type structFields struct {
+	path   Path
+	query  Query
+	header Header
+	cookie Cookie
+	form   Form
+	json   Map
+}
+
+var structTags = []string{"path", "query", "header", "cookie", "form", "json"}
+
+func (f *structFields) collect(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		tagged := false
+		for _, tag := range structTags {
+			name, omitEmpty, ok := parseStructTag(field, tag)
+			if !ok {
+				continue
+			}
+
+			tagged = true
+			if name == "-" || (omitEmpty && value.IsZero()) {
+				continue
+			}
+			f.set(tag, name, value)
+		}
+
+		if tagged {
+			continue
+		}
+
+		for value.Kind() == reflect.Ptr && !value.IsNil() {
+			value = value.Elem()
+		}
+		if value.Kind() == reflect.Struct {
+			f.collect(value)
+		}
+	}
+}
+
+func (f *structFields) set(tag, name string, value reflect.Value) {
+	if tag == "json" {
+		f.json[name] = value.Interface()
+		return
+	}
+
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+
+	v := structFieldValue(value)
+	switch tag {
+	case "path":
+		f.path[name] = v
+	case "query":
+		f.query[name] = v
+	case "header":
+		f.header[name] = v
+	case "cookie":
+		f.cookie[name] = v
+	case "form":
+		f.form[name] = v
+	}
+}
+
+func (f *structFields) params() []interface{} {
+	var params []interface{}
+	if len(f.path) > 0 {
+		params = append(params, f.path)
+	}
+	if len(f.query) > 0 {
+		params = append(params, f.query)
+	}
+	if len(f.header) > 0 {
+		params = append(params, f.header)
+	}
+	if len(f.cookie) > 0 {
+		params = append(params, f.cookie)
+	}
+	if len(f.form) > 0 {
+		params = append(params, f.form)
+	}
+	if len(f.json) > 0 {
+		params = append(params, Json{f.json})
+	}
+	return params
+}
+
+func parseStructTag(field reflect.StructField, key string) (name string, omitEmpty bool, ok bool) {
+	tag, ok := field.Tag.Lookup(key)
+	if !ok {
+		return "", false, false
+	}
+
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, option := range parts[1:] {
+		if option == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty, true
+}
+
+// structFieldValue converts a field into a value that Stringify and foreach understand.
+// encoding.TextMarshaler is preferred over fmt.Stringer, so that time.Time is formatted as RFC 3339.
+func structFieldValue(v reflect.Value) interface{} {
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		if b, err := m.MarshalText(); err == nil {
+			return string(b)
+		}
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint()
+	case reflect.Float32:
+		return float32(v.Float())
+	case reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return v.String()
+	case reflect.Array, reflect.Slice:
+		l := make(List, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			e := v.Index(i)
+			for e.Kind() == reflect.Ptr || e.Kind() == reflect.Interface {
+				if e.IsNil() {
+					break
+				}
+				e = e.Elem()
+			}
+			if e.Kind() == reflect.Ptr || e.Kind() == reflect.Interface {
+				continue
+			}
+			l = append(l, structFieldValue(e))
+		}
+		return l
+	}
+	return v.Interface()
+}
+
 func ToString(v interface{}) string {
 	var s string
 	switch x := v.(type) {
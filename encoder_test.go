@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestToString(t *testing.T) {
@@ -46,9 +47,9 @@ func TestResolveJsonBytes(t *testing.T) {
 
 	b, _ = ioutil.ReadAll(req.Body)
 	var n map[string]*json.RawMessage
-	json.Unmarshal(b, n)
+	json.Unmarshal(b, &n)
 
-	assert.Equal(t, "v", m["k"])
+	assert.Equal(t, json.RawMessage(`"v"`), *n["k"])
 }
 
 func TestResolveJsonList(t *testing.T) {
@@ -90,3 +91,89 @@ func TestWriteFile_Returns_Error_When_Fails_To_Create_File_Writer(t *testing.T)
 
 	assert.NotNil(t, err)
 }
+
+type pageParams struct {
+	Page *int     `query:"page,omitempty"`
+	Size int      `query:"size,omitempty"`
+	Tags []string `query:"tag"`
+}
+
+type bookParams struct {
+	pageParams
+	ID      int    `path:"id"`
+	TraceID string `header:"X-Trace"`
+	Session string `cookie:"sid"`
+	Name    string `json:"name"`
+	Author  *struct {
+		Name string `json:"author"`
+	}
+	Ignored string `json:"-"`
+}
+
+func TestStructEncoder_Encode(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "http://github.com/books/:id", nil)
+	page := 2
+	param := S{&bookParams{
+		pageParams: pageParams{Page: &page, Tags: []string{"a", "b"}},
+		ID:         1,
+		TraceID:    "trace",
+		Session:    "session",
+		Name:       "bookA",
+		Author: &struct {
+			Name string `json:"author"`
+		}{Name: "foo"},
+		Ignored: "ignored",
+	}}
+
+	err := NewEncoderChain(&RequestContext{Request: req, Params: L{param}, Param: param, ParamIndex: 0}, *Encoders...).Next()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/books/1", req.URL.Path)
+	assert.Equal(t, "2", req.URL.Query().Get("page"))
+	assert.Equal(t, []string{"a", "b"}, req.URL.Query()["tag"])
+	assert.NotContains(t, req.URL.Query(), "size")
+	assert.Equal(t, "trace", req.Header.Get("X-Trace"))
+	c, _ := req.Cookie("sid")
+	assert.Equal(t, "session", c.Value)
+	b, _ := ioutil.ReadAll(req.Body)
+	assert.JSONEq(t, `{"name":"bookA","author":"foo"}`, string(b))
+}
+
+func TestStructEncoder_Encode_Formats_Time_As_RFC3339(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://github.com/books", nil)
+	since := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("", 8*60*60))
+	param := S{struct {
+		Since  time.Time  `query:"since"`
+		Before *time.Time `header:"X-Before"`
+	}{Since: since, Before: &since}}
+
+	err := NewEncoderChain(&RequestContext{Request: req, Params: L{param}, Param: param, ParamIndex: 0}, *Encoders...).Next()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "2024-01-02T03:04:05+08:00", req.URL.Query().Get("since"))
+	assert.Equal(t, "2024-01-02T03:04:05+08:00", req.Header.Get("X-Before"))
+}
+
+func TestStructEncoder_Encode_Returns_Error_If_Payload_Is_Not_A_Struct(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://github.com", nil)
+
+	err := new(StructEncoder).Encode(&RequestContext{Request: req, Params: L{S{1}}, Param: S{1}, ParamIndex: 0}, nil)
+
+	assert.NotNil(t, err)
+}
+
+func TestEncoders_Set_Replayable_Body(t *testing.T) {
+	for _, param := range (L{J{M{"name": "bookA"}}, X{`<book name="bookA"></book>`}, "bookA", F{"name": "bookA"}, MP{"name": "bookA"}}) {
+		req, _ := http.NewRequest(http.MethodPost, "http://github.com", nil)
+
+		err := NewEncoderChain(&RequestContext{Request: req, Params: L{param}, Param: param, ParamIndex: 0}, *Encoders...).Next()
+		assert.Nil(t, err)
+
+		b, _ := ioutil.ReadAll(req.Body)
+		assert.Equal(t, int64(len(b)), req.ContentLength)
+		body, err := req.GetBody()
+		assert.Nil(t, err)
+		replayed, _ := ioutil.ReadAll(body)
+		assert.Equal(t, b, replayed)
+	}
+}
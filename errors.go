@@ -5,4 +5,10 @@ import "errors"
 var (
 	EncoderNotFound = errors.New("encoder not found")
 	DecoderNotFound = errors.New("decoder not found")
+	// BodyNotReplayable is returned when a request body has to be sent again but it can not be rewound.
+	BodyNotReplayable = errors.New("request body is not replayable")
+	// ErrCircuitOpen is returned by CircuitBreaker when a circuit is open.
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+	// ErrNoHarEntry is returned by HarTransporter when no recorded entry matches a request.
+	ErrNoHarEntry = errors.New("no HAR entry matches the request")
 )
@@ -0,0 +1,242 @@
+package sugar
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultEventRetry is the reconnection delay of Client.Events until the server sends a retry field.
+var DefaultEventRetry = 3 * time.Second
+
+// Event is a server-sent event.
+type Event struct {
+	// ID is the last event ID when the event is dispatched, which is sent as Last-Event-ID on reconnection.
+	ID string
+	// Type is the event field, default "message".
+	Type string
+	// Data is the data fields joined with "\n".
+	Data string
+	// Retry is the reconnection delay given along with the event, or 0 if there is none.
+	Retry time.Duration
+}
+
+// Events iterates server-sent events of a text/event-stream response, parsed as the WHATWG HTML standard specifies.
+// Iteration stops after an error is yielded or at the end of the stream, and the body is closed when iteration stops.
+// It does not reconnect, see Client.Events.
+func (r *Response) Events() iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		defer r.Close()
+
+		resp, err := r.Raw()
+		if err != nil {
+			yield(Event{}, err)
+			return
+		}
+
+		reader := newEventReader(resp.Body, "")
+		for {
+			event, err := reader.next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(Event{}, err)
+				return
+			}
+			if !yield(event, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Events sends a GET request and iterates server-sent events of the response.
+// When the stream ends or the connection fails, it reconnects after the delay set by the retry field,
+// default DefaultEventRetry, with the Last-Event-ID header. Every connection is a request of the client,
+// so that plugins and presets are applied to it, and Client.Timeout limits every connection.
+// Errors of connections are yielded before reconnecting, and iteration stops if the consumer breaks,
+// the context is done, the server responds 204 No Content, the response is not a successful event stream,
+// or the request fails for a reason which reconnecting can not fix, e.g. it can not be built.
+func (c *Client) Events(ctx context.Context, rawUrl string, params ...interface{}) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		lastEventID, retry := "", DefaultEventRetry
+		for {
+			header := Header{"Accept": ContentTypeEventStream, "Cache-Control": "no-cache"}
+			if lastEventID != "" {
+				header["Last-Event-ID"] = lastEventID
+			}
+
+			r := c.Do(ctx, http.MethodGet, rawUrl, append([]interface{}{header}, params...)...)
+			resp, err := r.Raw()
+			if err == nil {
+				if resp.StatusCode == http.StatusNoContent {
+					r.Close()
+					return
+				}
+				if err := checkEventStream(resp); err != nil {
+					r.Close()
+					yield(Event{}, err)
+					return
+				}
+
+				reader := newEventReader(resp.Body, lastEventID)
+				for {
+					var event Event
+					if event, err = reader.next(); err != nil {
+						break
+					}
+					if !yield(event, nil) {
+						r.Close()
+						return
+					}
+				}
+				r.Close()
+				lastEventID = reader.lastEventID
+				if reader.retry > 0 {
+					retry = reader.retry
+				}
+				if err == io.EOF {
+					err = nil
+				}
+			}
+
+			if ctx.Err() != nil {
+				return
+			}
+			if isPermanent(r, err) {
+				yield(Event{}, err)
+				return
+			}
+			if err != nil && !yield(Event{}, err) {
+				return
+			}
+			if sleep(ctx, retry) != nil {
+				return
+			}
+		}
+	}
+}
+
+// isPermanent reports whether a connection failed before it was sent or was rejected by the server,
+// rather than by an error of the transport or of reading the stream.
+func isPermanent(r *Response, err error) bool {
+	if err == nil {
+		return false
+	}
+	var httpErr *HTTPError
+	return r.request == nil || errors.Is(err, BodyNotReplayable) || errors.As(err, &httpErr)
+}
+
+// checkEventStream fails the connection if the response is not a successful event stream.
+func checkEventStream(resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("event stream: unexpected status %s", resp.Status)
+	}
+	if t := mediaType(resp.Header); t != ContentTypeEventStream {
+		return fmt.Errorf("event stream: unexpected content type %q", t)
+	}
+	return nil
+}
+
+type eventReader struct {
+	reader      *bufio.Reader
+	lastEventID string
+	retry       time.Duration
+	// skipLF is set after a line ends with "\r", because "\r\n" is a single line break.
+	skipLF  bool
+	started bool
+}
+
+func newEventReader(r io.Reader, lastEventID string) *eventReader {
+	return &eventReader{reader: bufio.NewReader(r), lastEventID: lastEventID}
+}
+
+// next reads lines until an event is dispatched. An incomplete event at the end of the stream is discarded.
+func (r *eventReader) next() (Event, error) {
+	var eventType string
+	var data strings.Builder
+	var retry time.Duration
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			return Event{}, err
+		}
+
+		if line == "" {
+			if data.Len() == 0 {
+				eventType, retry = "", 0
+				continue
+			}
+			event := Event{ID: r.lastEventID, Type: eventType, Data: strings.TrimSuffix(data.String(), "\n"), Retry: retry}
+			if event.Type == "" {
+				event.Type = "message"
+			}
+			return event, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				r.lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				retry = time.Duration(ms) * time.Millisecond
+				r.retry = retry
+			}
+		}
+	}
+}
+
+// readLine reads a line ending with "\r\n", "\n" or "\r". A leading byte order mark of the stream is skipped.
+func (r *eventReader) readLine() (string, error) {
+	var line bytes.Buffer
+	for {
+		b, err := r.reader.ReadByte()
+		if err != nil {
+			return "", err
+		}
+
+		if r.skipLF {
+			r.skipLF = false
+			if b == '\n' {
+				continue
+			}
+		}
+		switch b {
+		case '\n':
+			return r.line(line.Bytes()), nil
+		case '\r':
+			r.skipLF = true
+			return r.line(line.Bytes()), nil
+		}
+		line.WriteByte(b)
+	}
+}
+
+func (r *eventReader) line(b []byte) string {
+	if !r.started {
+		r.started = true
+		b = bytes.TrimPrefix(b, []byte("\xEF\xBB\xBF"))
+	}
+	return string(b)
+}
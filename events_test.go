@@ -0,0 +1,196 @@
+package sugar
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponse_Events(t *testing.T) {
+	resp, body := streamResponse("text/event-stream", "\xEF\xBB\xBF: comment\n"+
+		"data: first\n"+
+		"data:  second\n\n"+
+		"event: update\r\nid: 1\r\ndata: {\"name\":\"bookA\"}\r\n\r\n"+
+		"retry: 1500\rdata\r\r"+
+		"id\nretry: soon\ndata: no id\n\n"+
+		"event: ignored\n\n"+
+		"data: incomplete")
+
+	var events []Event
+	for event, err := range resp.Events() {
+		if !assert.NoError(t, err) {
+			break
+		}
+		events = append(events, event)
+	}
+
+	assert.Equal(t, []Event{
+		{Type: "message", Data: "first\n second"},
+		{ID: "1", Type: "update", Data: `{"name":"bookA"}`},
+		{ID: "1", Type: "message", Data: "", Retry: 1500 * time.Millisecond},
+		{ID: "", Type: "message", Data: "no id"},
+	}, events)
+	assert.True(t, body.closed)
+}
+
+func TestResponse_Events_Error(t *testing.T) {
+	requestErr := errors.New("connection refused")
+
+	for _, err := range (&Response{Error: requestErr}).Events() {
+		assert.Same(t, requestErr, err)
+	}
+}
+
+func TestClient_Events_Reconnects(t *testing.T) {
+	var connections, plugins int32
+	var lastEventIDs []string
+	client := NewClient(
+		WithTransporter(HandlerTransporter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "text/event-stream", r.Header.Get("Accept"))
+			assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+			lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+
+			switch atomic.AddInt32(&connections, 1) {
+			case 1:
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Write([]byte("retry: 10\nid: 1\ndata: a\n\n"))
+			case 2:
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Write([]byte("id: 2\ndata: b\n\n"))
+			default:
+				w.WriteHeader(http.StatusNoContent)
+			}
+		}))),
+		WithHeader("Authorization", "Bearer token"),
+		WithPlugins(PluginFunc(func(c *Context) error {
+			// The second connection fails before it reaches the server.
+			if atomic.AddInt32(&plugins, 1) == 2 {
+				return errors.New("connection reset")
+			}
+			return c.Next()
+		})),
+	)
+
+	var data []string
+	var errs []string
+	for event, err := range client.Events(context.Background(), "http://api.example.com/books/events") {
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		data = append(data, event.Data)
+	}
+
+	assert.Equal(t, []string{"a", "b"}, data)
+	assert.Equal(t, []string{"connection reset"}, errs)
+	assert.Equal(t, []string{"", "1", "2"}, lastEventIDs)
+	assert.Equal(t, int32(4), plugins)
+}
+
+func TestClient_Events_StopsOnBreak(t *testing.T) {
+	var connections int32
+	client := NewClient(WithTransporter(HandlerTransporter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&connections, 1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: a\n\ndata: b\n\n"))
+	}))))
+
+	for event := range client.Events(context.Background(), "http://api.example.com/books/events") {
+		assert.Equal(t, "a", event.Data)
+		break
+	}
+	assert.Equal(t, int32(1), connections)
+}
+
+func TestClient_Events_StopsOnContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := NewClient(WithTransporter(HandlerTransporter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: a\n\n"))
+	}))))
+
+	var count int
+	for _, err := range client.Events(ctx, "http://api.example.com/books/events") {
+		assert.NoError(t, err)
+		count++
+		cancel()
+	}
+	assert.Equal(t, 1, count)
+}
+
+func TestClient_Events_FailsConnection(t *testing.T) {
+	tests := []struct {
+		status       int
+		contentType  string
+		statusPolicy StatusPolicy
+		error        string
+	}{
+		{http.StatusOK, "application/json", nil, `event stream: unexpected content type "application/json"`},
+		{http.StatusServiceUnavailable, "text/event-stream", nil, "event stream: unexpected status 503 Service Unavailable"},
+		{http.StatusServiceUnavailable, "text/plain", Non2xx, "GET http://api.example.com/books/events: 503 Service Unavailable: unavailable"},
+	}
+
+	for _, test := range tests {
+		var connections int32
+		client := NewClient(
+			WithTransporter(HandlerTransporter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&connections, 1)
+				w.Header().Set("Content-Type", test.contentType)
+				w.WriteHeader(test.status)
+				w.Write([]byte("unavailable"))
+			}))),
+			WithStatusPolicy(test.statusPolicy),
+		)
+
+		var errs []string
+		for _, err := range client.Events(context.Background(), "http://api.example.com/books/events") {
+			errs = append(errs, err.Error())
+		}
+		assert.Equal(t, []string{test.error}, errs)
+		assert.Equal(t, int32(1), connections)
+	}
+}
+
+func TestClient_Events_StopsOnPermanentError(t *testing.T) {
+	tests := []struct {
+		rawUrl string
+		param  interface{}
+		plugin Plugin
+		error  string
+	}{
+		{"http://api.example.com/books/events", Struct{Payload: 1}, nil, "sugar: Struct payload must be a struct, got int"},
+		{"http://[::1/books/events", nil, nil, `parse "http://[::1/books/events": missing ']' in host`},
+		{"http://api.example.com/books/events", nil, PluginFunc(func(c *Context) error { return BodyNotReplayable }), BodyNotReplayable.Error()},
+	}
+
+	for _, test := range tests {
+		var connections int32
+		client := NewClient(WithTransporter(HandlerTransporter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&connections, 1)
+		}))))
+		var params []interface{}
+		if test.param != nil {
+			params = append(params, test.param)
+		}
+		if test.plugin != nil {
+			client.UsePlugin(test.plugin)
+		}
+
+		var errs []string
+		for _, err := range client.Events(context.Background(), test.rawUrl, params...) {
+			errs = append(errs, err.Error())
+			// Stops a reconnecting iteration, so that a failing test does not hang.
+			if len(errs) > 1 {
+				break
+			}
+		}
+		assert.Equal(t, []string{test.error}, errs)
+		assert.Equal(t, int32(0), connections)
+	}
+}
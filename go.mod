@@ -1,8 +1,15 @@
 module github.com/pojozhang/sugar
 
-go 1.13
+go 1.23
 
 require (
-	github.com/stretchr/testify v1.7.0 // indirect
-	gopkg.in/h2non/gock.v1 v1.1.0 // indirect
+	github.com/stretchr/testify v1.7.0
+	gopkg.in/h2non/gock.v1 v1.1.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/h2non/parth v0.0.0-20190131123155-b4df798d6542 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 )
@@ -0,0 +1,499 @@
+package sugar
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Har is an HTTP Archive 1.2 document.
+type Har struct {
+	Log HarLog `json:"log"`
+}
+
+// HarLog is the root of a HAR document.
+type HarLog struct {
+	Version string     `json:"version"`
+	Creator HarCreator `json:"creator"`
+	Entries []HarEntry `json:"entries"`
+}
+
+// HarCreator describes the application which created the log.
+type HarCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HarEntry is an exchange of a request and a response.
+type HarEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HarRequest  `json:"request"`
+	Response        HarResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HarTimings  `json:"timings"`
+}
+
+// HarRequest is a recorded request.
+type HarRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HarCookie    `json:"cookies"`
+	Headers     []HarNameValue `json:"headers"`
+	QueryString []HarNameValue `json:"queryString"`
+	PostData    *HarPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HarResponse is a recorded response.
+type HarResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HarCookie    `json:"cookies"`
+	Headers     []HarNameValue `json:"headers"`
+	Content     HarContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HarNameValue is a header or a query param.
+type HarNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HarCookie is a request or response cookie.
+type HarCookie struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Path     string     `json:"path,omitempty"`
+	Domain   string     `json:"domain,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	HTTPOnly bool       `json:"httpOnly,omitempty"`
+	Secure   bool       `json:"secure,omitempty"`
+}
+
+// HarPostData is a recorded request body.
+type HarPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HarContent is a recorded response body. Binary bodies are base64 encoded.
+type HarContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HarTimings are durations of phases of an exchange in milliseconds, -1 if a phase does not apply.
+type HarTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// HarRecorder is a plugin which writes every exchange into a HAR document.
+// Entries are streamed to Writer as soon as they complete, and Close must be called to finish the document.
+// Credentials in headers, cookies, urls and bodies are redacted as Logging does, so redacted requests are no longer
+// matched by HarTransporter. Bodies of event streams, NDJSON and JSON Lines are not recorded.
+// It must be used as a pointer, e.g. UsePlugin(&HarRecorder{Writer: f}).
+type HarRecorder struct {
+	Writer io.Writer
+	// RedactHeaders are redacted in addition to DefaultRedactedHeaders.
+	// Cookies are redacted together with the Cookie and Set-Cookie headers.
+	RedactHeaders []string
+	// RedactQuery are query params redacted in addition to DefaultRedactedFields.
+	RedactQuery []string
+	// RedactFields are form and json body fields redacted in addition to DefaultRedactedFields.
+	RedactFields []string
+
+	mu      sync.Mutex
+	entries int
+	closed  bool
+}
+
+// Handle sends the request and records it together with the response.
+func (r *HarRecorder) Handle(c *Context) error {
+	req := c.Request
+	reqBody, err := ReadRequestBody(req)
+	if err != nil {
+		return err
+	}
+
+	t := &harTrace{}
+	c.Request = req.WithContext(httptrace.WithClientTrace(req.Context(), t.clientTrace()))
+	t.start = time.Now()
+
+	err = c.Next()
+	t.headers = time.Now()
+	if err != nil || c.Response == nil {
+		return err
+	}
+
+	resp := c.Response
+	var respBody []byte
+	// Streams are left unread, because reading would block until the server ends the stream.
+	if resp.Body != nil && !isStreaming(mediaType(resp.Header)) {
+		respBody, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+	}
+	t.end = time.Now()
+
+	entry := HarEntry{
+		StartedDateTime: t.start,
+		Time:            milliseconds(t.end.Sub(t.start)),
+		Request:         harRequest(req, reqBody),
+		Response:        harResponse(resp, respBody),
+		Timings:         t.timings(),
+	}
+	r.redact(&entry)
+	return r.write(entry)
+}
+
+// redact replaces values of sensitive headers, cookies, query params and body fields by Redacted.
+func (r *HarRecorder) redact(entry *HarEntry) {
+	l := Logging{RedactHeaders: r.RedactHeaders, RedactQuery: r.RedactQuery, RedactFields: r.RedactFields}
+	redacts := func(name string) bool {
+		return containsFold(DefaultRedactedHeaders, name) || containsFold(r.RedactHeaders, name)
+	}
+	for _, headers := range [][]HarNameValue{entry.Request.Headers, entry.Response.Headers} {
+		for i := range headers {
+			if redacts(headers[i].Name) {
+				headers[i].Value = Redacted
+			}
+		}
+	}
+	if redacts("Cookie") {
+		for i := range entry.Request.Cookies {
+			entry.Request.Cookies[i].Value = Redacted
+		}
+	}
+	if redacts("Set-Cookie") {
+		for i := range entry.Response.Cookies {
+			entry.Response.Cookies[i].Value = Redacted
+		}
+	}
+
+	if u, err := url.Parse(entry.Request.URL); err == nil {
+		entry.Request.URL = l.redactUrl(u)
+	}
+	for i, q := range entry.Request.QueryString {
+		if containsFold(DefaultRedactedFields, q.Name) || containsFold(r.RedactQuery, q.Name) {
+			entry.Request.QueryString[i].Value = Redacted
+		}
+	}
+	if data := entry.Request.PostData; data != nil {
+		t, _, _ := mime.ParseMediaType(data.MimeType)
+		data.Text = l.redactBody(t, data.Text)
+	}
+	if content := &entry.Response.Content; content.Encoding == "" {
+		t, _, _ := mime.ParseMediaType(content.MimeType)
+		content.Text = l.redactBody(t, content.Text)
+	}
+}
+
+// Close writes the end of the HAR document. An empty document is written if no entry was recorded.
+func (r *HarRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+
+	if r.entries == 0 {
+		if _, err := io.WriteString(r.Writer, harHead); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(r.Writer, "]}}\n")
+	return err
+}
+
+const harHead = `{"log":{"version":"1.2","creator":{"name":"sugar","version":"2"},"entries":[`
+
+func (r *HarRecorder) write(entry HarEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	prefix := ","
+	if r.entries == 0 {
+		prefix = harHead
+	}
+	r.entries++
+	_, err = io.WriteString(r.Writer, prefix+string(b))
+	return err
+}
+
+func harRequest(req *http.Request, body []byte) HarRequest {
+	r := HarRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: httpVersion(req.Proto),
+		Cookies:     []HarCookie{},
+		Headers:     harHeaders(req.Header),
+		QueryString: []HarNameValue{},
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	for _, cookie := range req.Cookies() {
+		r.Cookies = append(r.Cookies, HarCookie{Name: cookie.Name, Value: cookie.Value})
+	}
+	for name, values := range req.URL.Query() {
+		for _, v := range values {
+			r.QueryString = append(r.QueryString, HarNameValue{Name: name, Value: v})
+		}
+	}
+	if body != nil {
+		r.PostData = &HarPostData{MimeType: req.Header.Get(ContentType), Text: string(body)}
+	}
+	return r
+}
+
+func harResponse(resp *http.Response, body []byte) HarResponse {
+	r := HarResponse{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: httpVersion(resp.Proto),
+		Cookies:     []HarCookie{},
+		Headers:     harHeaders(resp.Header),
+		Content:     HarContent{Size: len(body), MimeType: resp.Header.Get(ContentType)},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	for _, cookie := range resp.Cookies() {
+		c := HarCookie{Name: cookie.Name, Value: cookie.Value, Path: cookie.Path, Domain: cookie.Domain, HTTPOnly: cookie.HttpOnly, Secure: cookie.Secure}
+		if !cookie.Expires.IsZero() {
+			expires := cookie.Expires
+			c.Expires = &expires
+		}
+		r.Cookies = append(r.Cookies, c)
+	}
+	if utf8.Valid(body) {
+		r.Content.Text = string(body)
+	} else {
+		r.Content.Text, r.Content.Encoding = base64.StdEncoding.EncodeToString(body), "base64"
+	}
+	return r
+}
+
+func harHeaders(header http.Header) []HarNameValue {
+	headers := []HarNameValue{}
+	for name, values := range header {
+		for _, v := range values {
+			headers = append(headers, HarNameValue{Name: name, Value: v})
+		}
+	}
+	return headers
+}
+
+func httpVersion(proto string) string {
+	if proto == "" {
+		return "HTTP/1.1"
+	}
+	return proto
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// harTrace collects timings of an exchange via httptrace.
+type harTrace struct {
+	mu                     sync.Mutex
+	start, headers, end    time.Time
+	dnsStart, dnsDone      time.Time
+	connectStart, connDone time.Time
+	tlsStart, tlsDone      time.Time
+	gotConn, wrote, first  time.Time
+}
+
+func (t *harTrace) set(field *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if field.IsZero() {
+		*field = time.Now()
+	}
+}
+
+func (t *harTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { t.set(&t.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { t.set(&t.dnsDone) },
+		ConnectStart:         func(string, string) { t.set(&t.connectStart) },
+		ConnectDone:          func(string, string, error) { t.set(&t.connDone) },
+		TLSHandshakeStart:    func() { t.set(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.set(&t.tlsDone) },
+		GotConn:              func(httptrace.GotConnInfo) { t.set(&t.gotConn) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.set(&t.wrote) },
+		GotFirstResponseByte: func() { t.set(&t.first) },
+	}
+}
+
+func span(from, to time.Time) float64 {
+	if from.IsZero() || to.IsZero() {
+		return -1
+	}
+	return milliseconds(to.Sub(from))
+}
+
+// timings splits the exchange into HAR phases. Phases which are not traced, e.g. by a custom transporter,
+// are folded into wait, so that the sum of timings stays equal to the total time.
+func (t *harTrace) timings() HarTimings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	timings := HarTimings{
+		Blocked: -1,
+		DNS:     span(t.dnsStart, t.dnsDone),
+		Connect: span(t.connectStart, t.connDone),
+		SSL:     span(t.tlsStart, t.tlsDone),
+		Receive: milliseconds(t.end.Sub(t.headers)),
+	}
+	if t.gotConn.IsZero() || t.wrote.IsZero() || t.first.IsZero() {
+		timings.Send = 0
+		timings.Wait = milliseconds(t.headers.Sub(t.start))
+		return timings
+	}
+
+	connStart := t.gotConn
+	if !t.dnsStart.IsZero() {
+		connStart = t.dnsStart
+	} else if !t.connectStart.IsZero() {
+		connStart = t.connectStart
+	}
+	// Connect includes the TLS handshake in HAR.
+	if !t.tlsDone.IsZero() {
+		timings.Connect = span(t.connectStart, t.tlsDone)
+	}
+	timings.Blocked = span(t.start, connStart)
+	timings.Send = span(t.gotConn, t.wrote)
+	timings.Wait = span(t.wrote, t.first)
+	timings.Receive = milliseconds(t.end.Sub(t.first))
+	return timings
+}
+
+// HarTransporter is a Transporter which serves responses recorded in a HAR document.
+// Entries matching the same request are served in order, and the last one is served repeatedly.
+type HarTransporter struct {
+	// MatchBody also compares request bodies.
+	MatchBody bool
+
+	mu      sync.Mutex
+	entries []HarEntry
+	served  map[int]bool
+}
+
+// NewHarTransporter reads a HAR document.
+func NewHarTransporter(r io.Reader) (*HarTransporter, error) {
+	har := &Har{}
+	if err := json.NewDecoder(r).Decode(har); err != nil {
+		return nil, err
+	}
+	return &HarTransporter{entries: har.Log.Entries, served: map[int]bool{}}, nil
+}
+
+// Do finds the entry matching the request and returns its response, or an error wrapping ErrNoHarEntry.
+func (t *HarTransporter) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if t.MatchBody {
+		var err error
+		if body, err = ReadRequestBody(req); err != nil {
+			return nil, err
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	match := -1
+	for i, entry := range t.entries {
+		if !t.matches(entry.Request, req, body) {
+			continue
+		}
+		match = i
+		if !t.served[i] {
+			break
+		}
+	}
+	if match < 0 {
+		return nil, fmt.Errorf("%w: %s %s", ErrNoHarEntry, req.Method, req.URL)
+	}
+	t.served[match] = true
+	return t.entries[match].Response.response(req)
+}
+
+func (t *HarTransporter) matches(recorded HarRequest, req *http.Request, body []byte) bool {
+	if !strings.EqualFold(recorded.Method, req.Method) || recorded.URL != req.URL.String() {
+		return false
+	}
+	if !t.MatchBody {
+		return true
+	}
+	text := ""
+	if recorded.PostData != nil {
+		text = recorded.PostData.Text
+	}
+	return text == string(body)
+}
+
+func (r HarResponse) response(req *http.Request) (*http.Response, error) {
+	body := []byte(r.Content.Text)
+	if r.Content.Encoding == "base64" {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(r.Content.Text); err != nil {
+			return nil, err
+		}
+	}
+
+	header := http.Header{}
+	for _, h := range r.Headers {
+		header.Add(h.Name, h.Value)
+	}
+	// Bodies are recorded decoded, so headers describing the encoding on the wire no longer apply.
+	header.Del("Content-Encoding")
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	resp := NewResponse(req, r.Status, header, body)
+	resp.Status = strconv.Itoa(r.Status) + " " + r.StatusText
+	resp.Proto = httpVersion(r.HTTPVersion)
+	resp.ProtoMajor, resp.ProtoMinor, _ = http.ParseHTTPVersion(resp.Proto)
+	return resp, nil
+}
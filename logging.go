@@ -0,0 +1,319 @@
+package sugar
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Redacted replaces sensitive values in logs.
+const Redacted = "REDACTED"
+
+var (
+	// DefaultRedactedHeaders are headers which are always redacted by Logging.
+	DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+	// DefaultRedactedFields are query params and form or json body fields which are always redacted by Logging.
+	DefaultRedactedFields = []string{"password", "passwd", "secret", "token", "access_token", "refresh_token", "client_secret", "api_key", "apikey"}
+)
+
+// Logging is a plugin which writes a structured log record for every request via log/slog.
+// Credentials are redacted and bodies are truncated, so it is safe to be used in production.
+type Logging struct {
+	// Logger writes records, default slog.Default().
+	Logger *slog.Logger
+	// Level is the level of successful requests, default slog.LevelInfo.
+	Level slog.Leveler
+	// FailureLevel is the level of failed requests, default slog.LevelError.
+	FailureLevel slog.Leveler
+	// OnlyFailures skips successful requests.
+	OnlyFailures bool
+	// IsFailure classifies the result of a request, default errors and responses with status code >= 400.
+	IsFailure func(c *Context, err error) bool
+	// RedactHeaders are redacted in addition to DefaultRedactedHeaders.
+	RedactHeaders []string
+	// RedactQuery are query params redacted in addition to DefaultRedactedFields.
+	RedactQuery []string
+	// RedactFields are form and json body fields redacted in addition to DefaultRedactedFields.
+	RedactFields []string
+	// MaxBodySize is the maximum number of logged bytes of a body, default 4096. Bodies are not logged if it is negative.
+	MaxBodySize int
+}
+
+// Logger is a builtin plugin to log requests and responses via slog.Default() with credentials redacted.
+func Logger(c *Context) error {
+	return Logging{}.Handle(c)
+}
+
+// Handle logs the request and the response after the exchange is done.
+func (l Logging) Handle(c *Context) error {
+	logger := l.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	ctx := c.Request.Context()
+	if l.OnlyFailures && !logger.Enabled(ctx, leveler(l.FailureLevel, slog.LevelError)) {
+		return c.Next()
+	}
+	if !l.OnlyFailures && !logger.Enabled(ctx, leveler(l.Level, slog.LevelInfo)) && !logger.Enabled(ctx, leveler(l.FailureLevel, slog.LevelError)) {
+		return c.Next()
+	}
+
+	// The request may be replaced by following plugins, so it is captured before being sent.
+	req := c.Request
+	reqBody := l.peekRequestBody(req)
+	start := time.Now()
+
+	err := c.Next()
+	latency := time.Since(start)
+
+	isFailure := l.IsFailure
+	if isFailure == nil {
+		isFailure = func(c *Context, err error) bool {
+			return err != nil || (c.Response != nil && c.Response.StatusCode >= http.StatusBadRequest)
+		}
+	}
+	failure := isFailure(c, err)
+	if l.OnlyFailures && !failure {
+		return err
+	}
+
+	level := leveler(l.Level, slog.LevelInfo)
+	if failure {
+		level = leveler(l.FailureLevel, slog.LevelError)
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", l.redactUrl(req.URL)),
+		slog.String("route", routeTemplate(c)),
+		slog.Duration("latency", latency),
+		slog.Group("request", l.messageAttrs(req.Header, reqBody)...),
+	}
+	if c.Response != nil {
+		respBody := l.peekResponseBody(c.Response)
+		attrs = append(attrs,
+			slog.Int("status", c.Response.StatusCode),
+			slog.Group("response", l.messageAttrs(c.Response.Header, respBody)...),
+		)
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	logger.LogAttrs(ctx, level, "http request", attrs...)
+	return err
+}
+
+func leveler(l slog.Leveler, def slog.Level) slog.Level {
+	if l == nil {
+		return def
+	}
+	return l.Level()
+}
+
+func (l Logging) maxBodySize() int {
+	if l.MaxBodySize == 0 {
+		return 4096
+	}
+	return l.MaxBodySize
+}
+
+// loggedBody is the prefix of a body read for logging.
+type loggedBody struct {
+	header    http.Header
+	data      []byte
+	truncated bool
+}
+
+// peekRequestBody reads the prefix of a request body from a copy returned by GetBody,
+// or from the body itself which is then restored.
+func (l Logging) peekRequestBody(req *http.Request) *loggedBody {
+	if l.maxBodySize() < 0 || req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil
+		}
+		defer body.Close()
+		return newLoggedBody(req.Header, readPrefix(body, l.maxBodySize()), l.maxBodySize())
+	}
+
+	data := readPrefix(req.Body, l.maxBodySize())
+	req.Body = prefixedBody(data, req.Body)
+	return newLoggedBody(req.Header, data, l.maxBodySize())
+}
+
+// peekResponseBody reads the prefix of a response body and restores it.
+// Streams are never read, because reading would block until the server sends enough data.
+func (l Logging) peekResponseBody(resp *http.Response) *loggedBody {
+	if l.maxBodySize() < 0 || resp.Body == nil || resp.Body == http.NoBody {
+		return nil
+	}
+	if isStreaming(mediaType(resp.Header)) {
+		return nil
+	}
+
+	data := readPrefix(resp.Body, l.maxBodySize())
+	resp.Body = prefixedBody(data, resp.Body)
+	return newLoggedBody(resp.Header, data, l.maxBodySize())
+}
+
+// readPrefix reads at most n+1 bytes, so that a caller knows whether the body is longer than n.
+func readPrefix(r io.Reader, n int) []byte {
+	data, _ := io.ReadAll(io.LimitReader(r, int64(n)+1))
+	return data
+}
+
+func newLoggedBody(header http.Header, data []byte, max int) *loggedBody {
+	if len(data) > max {
+		return &loggedBody{header: header, data: data[:max], truncated: true}
+	}
+	return &loggedBody{header: header, data: data}
+}
+
+type multiReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// prefixedBody puts a consumed prefix back in front of a body.
+func prefixedBody(prefix []byte, body io.ReadCloser) io.ReadCloser {
+	return &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(prefix), body), Closer: body}
+}
+
+func (l Logging) messageAttrs(header http.Header, body *loggedBody) []any {
+	attrs := []any{slog.Any("header", l.redactHeader(header))}
+	if body != nil {
+		attrs = append(attrs, slog.String("body", l.formatBody(body)))
+	}
+	return attrs
+}
+
+func (l Logging) redactHeader(header http.Header) map[string]string {
+	redacted := make(map[string]string, len(header))
+	for k, v := range header {
+		if containsFold(DefaultRedactedHeaders, k) || containsFold(l.RedactHeaders, k) {
+			redacted[k] = Redacted
+		} else {
+			redacted[k] = strings.Join(v, ", ")
+		}
+	}
+	return redacted
+}
+
+func (l Logging) redactUrl(u *url.URL) string {
+	redacted := *u
+	if u.User != nil {
+		redacted.User = url.User(Redacted)
+	}
+
+	query := u.Query()
+	changed := false
+	for k := range query {
+		if containsFold(DefaultRedactedFields, k) || containsFold(l.RedactQuery, k) {
+			query[k] = []string{Redacted}
+			changed = true
+		}
+	}
+	if changed {
+		redacted.RawQuery = query.Encode()
+	}
+	return redacted.String()
+}
+
+func (l Logging) formatBody(body *loggedBody) string {
+	if isBinary(body.header, body.data, body.truncated) {
+		return "<binary body>"
+	}
+
+	s := l.redactBody(mediaType(body.header), string(body.data))
+	if body.truncated {
+		s += "...(truncated)"
+	}
+	return s
+}
+
+// redactBody replaces values of sensitive fields of a form or json body of the media type.
+func (l Logging) redactBody(t, s string) string {
+	fields := append(append([]string{}, DefaultRedactedFields...), l.RedactFields...)
+	switch {
+	case t == "application/x-www-form-urlencoded":
+		return redactForm(s, fields)
+	case t == "application/json" || strings.HasSuffix(t, "+json"):
+		return redactJson(s, fields)
+	}
+	return s
+}
+
+func redactForm(s string, fields []string) string {
+	pairs := strings.Split(s, "&")
+	for i, pair := range pairs {
+		name := strings.SplitN(pair, "=", 2)[0]
+		if unescaped, err := url.QueryUnescape(name); err == nil && containsFold(fields, unescaped) {
+			pairs[i] = name + "=" + Redacted
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// jsonRedactors caches the expressions of redactJson by the joined names of fields,
+// so that the expression of a Logging is compiled once rather than for every record.
+var jsonRedactors sync.Map
+
+// redactJson replaces values of the fields by a regular expression rather than a decoder, so that truncated bodies are redacted as well.
+func redactJson(s string, fields []string) string {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = regexp.QuoteMeta(f)
+	}
+	pattern := strings.Join(names, "|")
+
+	re, ok := jsonRedactors.Load(pattern)
+	if !ok {
+		re, _ = jsonRedactors.LoadOrStore(pattern, regexp.MustCompile(`(?i)("(?:`+pattern+`)"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^\s,}\]]+)`))
+	}
+	return re.(*regexp.Regexp).ReplaceAllString(s, `${1}"`+Redacted+`"`)
+}
+
+func mediaType(header http.Header) string {
+	t, _, _ := mime.ParseMediaType(header.Get(ContentType))
+	return t
+}
+
+// isBinary reports whether a body is not text by its content type, or by its content if the type is unknown.
+func isBinary(header http.Header, data []byte, truncated bool) bool {
+	t := mediaType(header)
+	switch {
+	case strings.HasPrefix(t, "text/"), strings.HasSuffix(t, "json"), strings.HasSuffix(t, "xml"),
+		t == "application/x-www-form-urlencoded", t == "application/javascript", t == "application/yaml":
+		return false
+	case strings.HasPrefix(t, "image/"), strings.HasPrefix(t, "audio/"), strings.HasPrefix(t, "video/"),
+		strings.HasPrefix(t, "multipart/"), t == "application/octet-stream", t == "application/pdf", t == "application/zip":
+		return true
+	}
+
+	// A truncated body may end in the middle of a rune.
+	for i := 0; truncated && i < utf8.UTFMax-1 && len(data) > 0 && !utf8.Valid(data); i++ {
+		data = data[:len(data)-1]
+	}
+	return !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package sugar
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// DefaultDurationBuckets are upper bounds of the request duration histogram in seconds.
+	DefaultDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	// DefaultSizeBuckets are upper bounds of the response size histogram in bytes.
+	DefaultSizeBuckets = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+)
+
+// Metrics is a plugin which collects request count, latency, in-flight requests and response sizes.
+// Requests are labeled by method, host, status class and the url template before path params are encoded,
+// so that the cardinality stays bounded. It exports metrics in Prometheus text format as an http.Handler.
+// It must be used as a pointer, e.g. UsePlugin(&Metrics{}).
+type Metrics struct {
+	// Namespace prefixes metric names, default "sugar".
+	Namespace       string
+	DurationBuckets []float64
+	SizeBuckets     []float64
+
+	mu       sync.Mutex
+	requests map[metricLabels]uint64
+	inFlight map[metricLabels]int64
+	duration map[metricLabels]*histogram
+	size     map[metricLabels]*histogram
+}
+
+type metricLabels struct {
+	method, host, route, status string
+}
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func (h *histogram) observe(v float64) {
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// Handle records metrics of the request.
+func (m *Metrics) Handle(c *Context) error {
+	labels := metricLabels{method: c.Request.Method, host: c.Request.URL.Host, route: routeTemplate(c)}
+	m.addInFlight(labels, 1)
+	start := time.Now()
+
+	err := c.Next()
+
+	m.addInFlight(labels, -1)
+	labels.status = "error"
+	if err == nil && c.Response != nil {
+		labels.status = strconv.Itoa(c.Response.StatusCode/100) + "xx"
+	}
+
+	m.mu.Lock()
+	m.init()
+	m.requests[labels]++
+	m.histogram(m.duration, labels, m.durationBuckets()).observe(time.Since(start).Seconds())
+	m.mu.Unlock()
+
+	if err == nil && c.Response != nil {
+		if c.Response.ContentLength >= 0 || c.Response.Body == nil {
+			m.observeSize(labels, float64(c.Response.ContentLength))
+		} else {
+			c.Response.Body = &countingBody{ReadCloser: c.Response.Body, done: func(n int64) {
+				m.observeSize(labels, float64(n))
+			}}
+		}
+	}
+	return err
+}
+
+func (m *Metrics) init() {
+	if m.requests == nil {
+		m.requests = map[metricLabels]uint64{}
+		m.inFlight = map[metricLabels]int64{}
+		m.duration = map[metricLabels]*histogram{}
+		m.size = map[metricLabels]*histogram{}
+	}
+}
+
+func (m *Metrics) durationBuckets() []float64 {
+	if m.DurationBuckets != nil {
+		return m.DurationBuckets
+	}
+	return DefaultDurationBuckets
+}
+
+func (m *Metrics) sizeBuckets() []float64 {
+	if m.SizeBuckets != nil {
+		return m.SizeBuckets
+	}
+	return DefaultSizeBuckets
+}
+
+func (m *Metrics) histogram(hs map[metricLabels]*histogram, labels metricLabels, buckets []float64) *histogram {
+	h, ok := hs[labels]
+	if !ok {
+		h = &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+		hs[labels] = h
+	}
+	return h
+}
+
+func (m *Metrics) addInFlight(labels metricLabels, delta int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+	m.inFlight[labels] += delta
+}
+
+func (m *Metrics) observeSize(labels metricLabels, size float64) {
+	if size < 0 {
+		size = 0
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+	m.histogram(m.size, labels, m.sizeBuckets()).observe(size)
+}
+
+// countingBody counts bytes read from a body of unknown length and reports the total once.
+type countingBody struct {
+	io.ReadCloser
+	n    int64
+	once sync.Once
+	done func(n int64)
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err == io.EOF {
+		b.once.Do(func() { b.done(b.n) })
+	}
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	b.once.Do(func() { b.done(b.n) })
+	return b.ReadCloser.Close()
+}
+
+// ServeHTTP writes metrics in Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(ContentType, "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes metrics in Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	ns := m.Namespace
+	if ns == "" {
+		ns = "sugar"
+	}
+
+	b := &strings.Builder{}
+	name := ns + "_requests_total"
+	fmt.Fprintf(b, "# HELP %s Total number of requests.\n# TYPE %s counter\n", name, name)
+	for _, l := range sortedLabels(m.requests) {
+		fmt.Fprintf(b, "%s{%s} %d\n", name, l.format(true), m.requests[l])
+	}
+
+	name = ns + "_requests_in_flight"
+	fmt.Fprintf(b, "# HELP %s Number of requests in flight.\n# TYPE %s gauge\n", name, name)
+	for _, l := range sortedLabels(m.inFlight) {
+		fmt.Fprintf(b, "%s{%s} %d\n", name, l.format(false), m.inFlight[l])
+	}
+
+	writeHistograms(b, ns+"_request_duration_seconds", "Duration of requests in seconds.", m.duration)
+	writeHistograms(b, ns+"_response_size_bytes", "Size of response bodies in bytes.", m.size)
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func writeHistograms(b *strings.Builder, name, help string, hs map[metricLabels]*histogram) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for _, l := range sortedLabels(hs) {
+		h, labels := hs[l], l.format(true)
+		for i, upper := range h.buckets {
+			fmt.Fprintf(b, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, strconv.FormatFloat(upper, 'g', -1, 64), h.counts[i])
+		}
+		fmt.Fprintf(b, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+		fmt.Fprintf(b, "%s_sum{%s} %s\n", name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(b, "%s_count{%s} %d\n", name, labels, h.count)
+	}
+}
+
+func (l metricLabels) format(withStatus bool) string {
+	s := fmt.Sprintf(`method="%s",host="%s",route="%s"`, escapeLabel(l.method), escapeLabel(l.host), escapeLabel(l.route))
+	if withStatus {
+		s += fmt.Sprintf(`,status="%s"`, escapeLabel(l.status))
+	}
+	return s
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func sortedLabels[V any](m map[metricLabels]V) []metricLabels {
+	labels := make([]metricLabels, 0, len(m))
+	for l := range m {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		a, b := labels[i], labels[j]
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		if a.host != b.host {
+			return a.host < b.host
+		}
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		return a.status < b.status
+	})
+	return labels
+}
@@ -0,0 +1,51 @@
+package sugar
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	m := &Metrics{DurationBuckets: []float64{1}, SizeBuckets: []float64{10}}
+	ok := &mockTransporter{response: &http.Response{StatusCode: http.StatusOK, ContentLength: 5}}
+	unknown := &mockTransporter{response: &http.Response{StatusCode: http.StatusNotFound, ContentLength: -1, Body: ioutil.NopCloser(strings.NewReader("not found!!"))}}
+	failed := &mockTransporter{error: errors.New("error")}
+	send := func(rawUrl, url string, t Transporter) *Context {
+		c := newPluginContext(t, http.MethodGet, url, m)
+		c.RawUrl = rawUrl
+		c.Next()
+		return c
+	}
+
+	send("http://api.example.com/books/:id", "http://api.example.com/books/1", ok)
+	send("http://api.example.com/books/:id", "http://api.example.com/books/2", ok)
+	c := send("http://api.example.com/books/:id", "http://api.example.com/books/3", unknown)
+	ioutil.ReadAll(c.Response.Body)
+	c.Response.Body.Close()
+	send("http://api.example.com/books", "http://api.example.com/books", failed)
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, nil)
+	s := w.Body.String()
+
+	assert.Contains(t, w.Header().Get(ContentType), "text/plain; version=0.0.4")
+	assert.Contains(t, s, "# TYPE sugar_requests_total counter\n")
+	assert.Contains(t, s, `sugar_requests_total{method="GET",host="api.example.com",route="/books/:id",status="2xx"} 2`)
+	assert.Contains(t, s, `sugar_requests_total{method="GET",host="api.example.com",route="/books/:id",status="4xx"} 1`)
+	assert.Contains(t, s, `sugar_requests_total{method="GET",host="api.example.com",route="/books",status="error"} 1`)
+	assert.Contains(t, s, `sugar_requests_in_flight{method="GET",host="api.example.com",route="/books/:id"} 0`)
+	assert.Contains(t, s, `sugar_request_duration_seconds_count{method="GET",host="api.example.com",route="/books/:id",status="2xx"} 2`)
+	assert.Contains(t, s, `sugar_response_size_bytes_bucket{method="GET",host="api.example.com",route="/books/:id",status="2xx",le="10"} 2`)
+	assert.Contains(t, s, `sugar_response_size_bytes_sum{method="GET",host="api.example.com",route="/books/:id",status="4xx"} 11`)
+	assert.NotContains(t, s, "/books/1")
+}
+
+func TestEscapeLabel(t *testing.T) {
+	assert.Equal(t, `a\"b\\c\nd`, escapeLabel("a\"b\\c\nd"))
+}
@@ -7,6 +7,11 @@ const (
 	ContentTypeJsonUtf8    = "application/json; charset=UTF-8"
 	ContentTypeXml         = "application/xml"
 	ContentTypeXmlUtf8     = "application/xml; charset=UTF-8"
+	ContentTypeProblemJson = "application/problem+json"
+	ContentTypeProblemXml  = "application/problem+xml"
+	ContentTypeNdjson      = "application/x-ndjson"
+	ContentTypeJsonLines   = "application/jsonl"
+	ContentTypeEventStream = "text/event-stream"
 	ContentTypePlainText   = "text/plain"
 	ContentTypeOctetStream = "application/octet-stream"
 )
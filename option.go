@@ -0,0 +1,218 @@
+package sugar
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"reflect"
+	"time"
+)
+
+// Option configures a Client.
+type Option func(c *Client)
+
+// NewClient returns a Client configured by options, with encoders and decoders copied from the default client.
+// An http.Client is used as the transporter unless WithTransporter is given.
+func NewClient(options ...Option) *Client {
+	c := &Client{
+		Encoders: append(EncoderGroup(nil), defaultClient.Encoders...),
+		Decoders: append(DecoderGroup(nil), defaultClient.Decoders...),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	if c.Transporter == nil {
+		c.Transporter = &http.Client{}
+	}
+	return c
+}
+
+// WithTransporter sets the transporter.
+func WithTransporter(transporter Transporter) Option {
+	return func(c *Client) {
+		c.Transporter = transporter
+	}
+}
+
+// WithEncoders adds encoders.
+func WithEncoders(encoders ...Encoder) Option {
+	return func(c *Client) {
+		c.Encoders = append(c.Encoders[:len(c.Encoders):len(c.Encoders)], encoders...)
+	}
+}
+
+// WithDecoders adds decoders.
+func WithDecoders(decoders ...Decoder) Option {
+	return func(c *Client) {
+		c.Decoders = append(c.Decoders[:len(c.Decoders):len(c.Decoders)], decoders...)
+	}
+}
+
+// WithPlugins appends plugins.
+func WithPlugins(plugins ...Plugin) Option {
+	return func(c *Client) {
+		c.Plugins = append(c.Plugins[:len(c.Plugins):len(c.Plugins)], plugins...)
+	}
+}
+
+// WithPresets appends params attached to every request, as Apply does.
+func WithPresets(presets ...interface{}) Option {
+	return func(c *Client) {
+		c.Presets = append(c.Presets[:len(c.Presets):len(c.Presets)], presets...)
+	}
+}
+
+// WithStatusPolicy sets the policy which turns failed responses into *HTTPError.
+func WithStatusPolicy(policy StatusPolicy) Option {
+	return func(c *Client) {
+		c.StatusPolicy = policy
+	}
+}
+
+// WithErrorBody registers the type of error bodies for every request, as Apply(ErrorBody{v}) does.
+func WithErrorBody(v interface{}) Option {
+	return WithPresets(ErrorBody{v})
+}
+
+// WithBaseUrl sets the base url which relative urls are resolved against.
+func WithBaseUrl(baseUrl string) Option {
+	return func(c *Client) {
+		c.BaseUrl = baseUrl
+	}
+}
+
+// WithHeader adds a default header.
+func WithHeader(name, value string) Option {
+	return func(c *Client) {
+		if c.Header == nil {
+			c.Header = http.Header{}
+		}
+		c.Header.Add(name, value)
+	}
+}
+
+// WithUserAgent sets the default User-Agent header.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.UserAgent = userAgent
+	}
+}
+
+// WithTimeout limits every request including reading the response body.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.Timeout = timeout
+	}
+}
+
+// WithTLSConfig sets the TLS config of the http.Transport.
+// Transport options have no effect if the transporter is not an *http.Client using an *http.Transport.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(c *Client) {
+		if t := httpTransport(c); t != nil {
+			t.TLSClientConfig = config
+		}
+	}
+}
+
+// WithProxy sets the proxy of the http.Transport, e.g. WithProxy(http.ProxyURL(u)).
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) Option {
+	return func(c *Client) {
+		if t := httpTransport(c); t != nil {
+			t.Proxy = proxy
+		}
+	}
+}
+
+// WithCookieJar sets the cookie jar of the http.Client.
+func WithCookieJar(jar http.CookieJar) Option {
+	return func(c *Client) {
+		if hc := httpClient(c); hc != nil {
+			hc.Jar = jar
+		}
+	}
+}
+
+// httpClient replaces the transporter with a copy of the http.Client, or a new one if there is no transporter,
+// so that clients derived by With never modify the http.Client of the original.
+func httpClient(c *Client) *http.Client {
+	switch t := c.Transporter.(type) {
+	case nil:
+		hc := &http.Client{}
+		c.Transporter = hc
+		return hc
+	case *http.Client:
+		hc := *t
+		c.Transporter = &hc
+		return &hc
+	}
+	return nil
+}
+
+// httpTransport replaces the transport of the http.Client with a copy, or with a copy of http.DefaultTransport if it is nil.
+func httpTransport(c *Client) *http.Transport {
+	hc := httpClient(c)
+	if hc == nil {
+		return nil
+	}
+
+	var t *http.Transport
+	switch transport := hc.Transport.(type) {
+	case nil:
+		t = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		t = transport.Clone()
+	default:
+		return nil
+	}
+	hc.Transport = t
+	return t
+}
+
+// Clone returns a copy of the client. Encoders, decoders, plugins, presets and default headers are copied,
+// so that changes to either client do not affect the other. Plugins and encoders themselves are shared.
+func (c *Client) Clone() *Client {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	clone := &Client{
+		Transporter:  c.Transporter,
+		Encoders:     append(EncoderGroup(nil), c.Encoders...),
+		Decoders:     append(DecoderGroup(nil), c.Decoders...),
+		Plugins:      append([]Plugin(nil), c.Plugins...),
+		Presets:      make([]interface{}, len(c.Presets)),
+		BaseUrl:      c.BaseUrl,
+		Header:       c.Header.Clone(),
+		UserAgent:    c.UserAgent,
+		Timeout:      c.Timeout,
+		StatusPolicy: c.StatusPolicy,
+	}
+	for i, preset := range c.Presets {
+		clone.Presets[i] = clonePreset(preset)
+	}
+	return clone
+}
+
+// With returns a clone of the client configured by options.
+func (c *Client) With(options ...Option) *Client {
+	clone := c.Clone()
+	for _, option := range options {
+		option(clone)
+	}
+	return clone
+}
+
+// clonePreset copies map params such as Header{} and Query{}, which may be modified after being applied.
+func clonePreset(preset interface{}) interface{} {
+	v := reflect.ValueOf(preset)
+	if v.Kind() != reflect.Map || v.IsNil() {
+		return preset
+	}
+
+	clone := reflect.MakeMapWithSize(v.Type(), v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		clone.SetMapIndex(iter.Key(), iter.Value())
+	}
+	return clone.Interface()
+}
@@ -0,0 +1,137 @@
+package sugar
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDoesNotShareEncoders(t *testing.T) {
+	// Spare capacity makes appends of clients sharing the backing array overwrite each other.
+	encoders := defaultClient.Encoders
+	defer func() { defaultClient.Encoders = encoders }()
+	defaultClient.Encoders = append(make(EncoderGroup, 0, len(encoders)+1), encoders...)
+
+	a, b := New(StandardClient), New(StandardClient)
+	jsonEncoder, xmlEncoder := &JsonEncoder{}, &XmlEncoder{}
+	a.Encoders.Add(jsonEncoder)
+	b.Encoders.Add(xmlEncoder)
+
+	assert.Same(t, jsonEncoder, a.Encoders[len(encoders)])
+	assert.Same(t, xmlEncoder, b.Encoders[len(encoders)])
+	assert.Len(t, defaultClient.Encoders, len(encoders))
+}
+
+func TestClient_Clone(t *testing.T) {
+	base := New(StandardClient)
+	base.Header = http.Header{"Accept": {"application/json"}}
+	base.Apply(Header{"X-Tenant": "a"})
+	base.Use(Logger)
+
+	clone := base.Clone()
+	clone.Header.Set("Accept", "application/xml")
+	clone.Presets[0].(Header)["X-Tenant"] = "b"
+	clone.Apply(Query{"lang": "en"})
+	clone.UsePlugin(&mockPlugin{})
+	clone.Encoders.Add(&JsonEncoder{})
+	clone.Decoders.Add(&JsonDecoder{})
+
+	assert.Equal(t, "application/json", base.Header.Get("Accept"))
+	assert.Equal(t, []interface{}{Header{"X-Tenant": "a"}}, base.Presets)
+	assert.Len(t, base.Plugins, 1)
+	assert.Len(t, clone.Plugins, 2)
+	assert.Equal(t, len(*Encoders), len(base.Encoders))
+	assert.Equal(t, len(*Decoders), len(base.Decoders))
+	assert.Equal(t, base.Transporter, clone.Transporter)
+}
+
+func TestClient_With(t *testing.T) {
+	var req *http.Request
+	base := New(func() Transporter {
+		return HandlerTransporter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req = r
+		}))
+	})
+	base.Apply(User{"user", "password"})
+
+	plugin := &mockPlugin{}
+	books := base.With(
+		WithBaseUrl("http://books.example.com/v1"),
+		WithHeader("Accept", "application/json"),
+		WithUserAgent("books/1.0"),
+		WithPresets(Query{"lang": "en"}),
+		WithPlugins(plugin),
+	)
+	_, err := books.Get(context.Background(), "/books").Raw()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "books.example.com", req.Host)
+	assert.Equal(t, "/v1/books?lang=en", req.RequestURI)
+	assert.Equal(t, "application/json", req.Header.Get("Accept"))
+	assert.Equal(t, "books/1.0", req.UserAgent())
+	user, password, _ := req.BasicAuth()
+	assert.Equal(t, "user", user)
+	assert.Equal(t, "password", password)
+	assert.Equal(t, 1, plugin.count)
+	assert.Empty(t, base.BaseUrl)
+	assert.Len(t, base.Presets, 1)
+	assert.Empty(t, base.Plugins)
+}
+
+func TestNewClient_Options(t *testing.T) {
+	jar, _ := cookiejar.New(nil)
+	tlsConfig := &tls.Config{ServerName: "api.example.com"}
+	proxyUrl, _ := url.Parse("http://proxy.example.com:8080")
+	plugin := &mockPlugin{}
+
+	client := NewClient(
+		WithBaseUrl("http://api.example.com"),
+		WithTimeout(time.Second),
+		WithTLSConfig(tlsConfig),
+		WithProxy(http.ProxyURL(proxyUrl)),
+		WithCookieJar(jar),
+		WithPlugins(plugin),
+		WithPresets(Header{"X-Tenant": "a"}),
+		WithEncoders(&JsonEncoder{}),
+		WithDecoders(&JsonDecoder{}),
+	)
+
+	hc := client.Transporter.(*http.Client)
+	transport := hc.Transport.(*http.Transport)
+	assert.Same(t, jar, hc.Jar)
+	assert.Equal(t, "api.example.com", transport.TLSClientConfig.ServerName)
+	req, _ := http.NewRequest(http.MethodGet, "http://api.example.com", nil)
+	proxy, _ := transport.Proxy(req)
+	assert.Equal(t, proxyUrl, proxy)
+	assert.NotSame(t, http.DefaultTransport, transport)
+	assert.Equal(t, time.Second, client.Timeout)
+	assert.Equal(t, "http://api.example.com", client.BaseUrl)
+	assert.Equal(t, []Plugin{plugin}, client.Plugins)
+	assert.Equal(t, []interface{}{Header{"X-Tenant": "a"}}, client.Presets)
+	assert.Equal(t, len(*Encoders)+1, len(client.Encoders))
+	assert.Equal(t, len(*Decoders)+1, len(client.Decoders))
+	assert.Len(t, *Encoders, len(defaultClient.Encoders))
+}
+
+func TestNewClient_DefaultTransporter(t *testing.T) {
+	client := NewClient()
+	assert.IsType(t, &http.Client{}, client.Transporter)
+
+	transporter := HandlerTransporter(http.NotFoundHandler())
+	client = NewClient(WithTransporter(transporter), WithTLSConfig(&tls.Config{}))
+	assert.Equal(t, transporter, client.Transporter)
+}
+
+func TestClient_WithDoesNotModifyHttpClient(t *testing.T) {
+	base := NewClient(WithTLSConfig(&tls.Config{ServerName: "a"}))
+	derived := base.With(WithTLSConfig(&tls.Config{ServerName: "b"}))
+
+	assert.Equal(t, "a", base.Transporter.(*http.Client).Transport.(*http.Transport).TLSClientConfig.ServerName)
+	assert.Equal(t, "b", derived.Transporter.(*http.Client).Transport.(*http.Transport).TLSClientConfig.ServerName)
+}
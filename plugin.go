@@ -1,10 +1,7 @@
 package sugar
 
 import (
-	"log"
-	"net"
 	"net/http"
-	"net/http/httputil"
 	"time"
 )
 
@@ -25,42 +22,32 @@ func (f PluginFunc) Handle(c *Context) error {
 	return f(c)
 }
 
-// Logger is a builtin plugin to dump requests and responses.
-func Logger(c *Context) error {
-	b, _ := httputil.DumpRequest(c.Request, true)
-	log.Println(string(b))
-	defer func() {
-		if c.Response != nil {
-			b, _ := httputil.DumpResponse(c.Response, true)
-			log.Println(string(b))
-		}
-	}()
-	return c.Next()
-}
-
 // Retryer provides a common policy to retry the request.
+// It is a shortcut for RetryPolicy which also retries non-idempotent requests.
 func Retryer(attempts int, delay time.Duration, multiplier float32, maxDelay time.Duration) func(c *Context) error {
-	return func(c *Context) (err error) {
-		for d, i := delay, 0; i < attempts; i++ {
-			err = c.Next()
-			if c.Response != nil && c.Response.StatusCode < http.StatusInternalServerError {
-				return
-			}
+	return RetryPolicy{
+		Attempts:           attempts,
+		Delay:              delay,
+		Multiplier:         float64(multiplier),
+		MaxDelay:           maxDelay,
+		RetryNonIdempotent: true,
+	}.Handle
+}
 
-			if _, ok := err.(net.Error); err == nil || !ok {
-				return
-			}
+// rewindBody resets the request body via GetBody before the request is sent again.
+func rewindBody(req *http.Request) error {
+	if req == nil || req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
 
-			if i < attempts-1 {
-				time.Sleep(d)
-				if t := d * time.Duration(multiplier); maxDelay < t {
-					d = maxDelay
-				} else {
-					d = t
-				}
-			}
-		}
+	if req.GetBody == nil {
+		return BodyNotReplayable
+	}
 
-		return
+	body, err := req.GetBody()
+	if err != nil {
+		return err
 	}
+	req.Body = body
+	return nil
 }
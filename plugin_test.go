@@ -1,9 +1,12 @@
 package sugar
 
 import (
+	"context"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
 	"net"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 )
@@ -11,14 +14,33 @@ import (
 type mockTransporter struct {
 	count    int
 	response *http.Response
-	error    error
+	// responses are returned in order instead of response, and the last one is returned repeatedly.
+	responses []*http.Response
+	error     error
+	// requests are the requests sent via the transporter.
+	requests []*http.Request
 }
 
 func (t *mockTransporter) Do(req *http.Request) (*http.Response, error) {
 	t.count++
+	t.requests = append(t.requests, req)
+	if len(t.responses) > 0 {
+		resp := t.responses[0]
+		if len(t.responses) > 1 {
+			t.responses = t.responses[1:]
+		}
+		return resp, t.error
+	}
 	return t.response, t.error
 }
 
+// newPluginContext returns a context which sends a request through the plugins and then the transporter.
+// RawUrl is the url of the request, and tests of url templates set it afterwards.
+func newPluginContext(t Transporter, method, url string, plugins ...Plugin) *Context {
+	req, _ := http.NewRequest(method, url, nil)
+	return &Context{Request: req, Method: method, RawUrl: url, transporter: t, plugins: plugins}
+}
+
 func TestRetryer_Retry_If_Transporter_Returns_An_Error_Of_NetError(t *testing.T) {
 	const attempts = 3
 	p := PluginFunc(Retryer(attempts, time.Duration(1)*time.Second, 1.5, time.Duration(3)*time.Second))
@@ -60,3 +82,43 @@ func TestRetryer_Not_Retry_If_Request_Is_Handled_Correctly(t *testing.T) {
 
 	assert.Equal(t, attempts, m.count)
 }
+
+type bodyRecordingTransporter struct {
+	bodies []string
+}
+
+func (t *bodyRecordingTransporter) Do(req *http.Request) (*http.Response, error) {
+	b, _ := ioutil.ReadAll(req.Body)
+	t.bodies = append(t.bodies, string(b))
+	return nil, &net.OpError{}
+}
+
+func TestRetryer_Resend_Request_Body(t *testing.T) {
+	const attempts = 3
+	req, _ := New(StandardClient).NewRequest(context.Background(), http.MethodPost, "http://api.example.com/books", Json{`{"name":"bookA"}`})
+	m := &bodyRecordingTransporter{}
+	c := Context{
+		Request:     req,
+		transporter: m,
+		plugins:     []Plugin{PluginFunc(Retryer(attempts, time.Millisecond, 1, time.Millisecond))},
+	}
+
+	c.Next()
+
+	assert.Equal(t, []string{`{"name":"bookA"}`, `{"name":"bookA"}`, `{"name":"bookA"}`}, m.bodies)
+}
+
+func TestRetryer_Returns_Error_If_Request_Body_Is_Not_Replayable(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "http://api.example.com/books", ioutil.NopCloser(strings.NewReader("bookA")))
+	m := &bodyRecordingTransporter{}
+	c := Context{
+		Request:     req,
+		transporter: m,
+		plugins:     []Plugin{PluginFunc(Retryer(3, time.Millisecond, 1, time.Millisecond))},
+	}
+
+	err := c.Next()
+
+	assert.Equal(t, BodyNotReplayable, err)
+	assert.Equal(t, []string{"bookA"}, m.bodies)
+}
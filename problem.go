@@ -0,0 +1,215 @@
+package sugar
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Problem is a problem details document of RFC 7807. It is returned as an error by Response.Read
+// when the server responds with application/problem+json or application/problem+xml.
+type Problem struct {
+	// Type is a URI reference identifying the problem type, default "about:blank".
+	Type     string
+	Title    string
+	Status   int
+	Detail   string
+	Instance string
+	// Extensions keeps members other than the standard ones.
+	// Values of the XML variant are strings, lists of <i> elements and maps of child elements.
+	Extensions map[string]interface{}
+}
+
+func (p *Problem) Error() string {
+	var parts []string
+	if p.Status > 0 {
+		parts = append(parts, strconv.Itoa(p.Status))
+	}
+	title := p.Title
+	if title == "" {
+		title = http.StatusText(p.Status)
+	}
+	if title != "" {
+		parts = append(parts, title)
+	}
+
+	msg := strings.Join(parts, " ")
+	if msg == "" {
+		msg = "problem"
+	}
+	if p.Detail != "" {
+		msg += ": " + p.Detail
+	}
+	return msg
+}
+
+// UnmarshalJSON decodes standard members and keeps the others in Extensions.
+// Standard members of wrong types are ignored as RFC 7807 requires.
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+
+	*p = Problem{}
+	for name, value := range members {
+		switch name {
+		case "type":
+			json.Unmarshal(value, &p.Type)
+		case "title":
+			json.Unmarshal(value, &p.Title)
+		case "status":
+			json.Unmarshal(value, &p.Status)
+		case "detail":
+			json.Unmarshal(value, &p.Detail)
+		case "instance":
+			json.Unmarshal(value, &p.Instance)
+		default:
+			var v interface{}
+			if err := json.Unmarshal(value, &v); err != nil {
+				return err
+			}
+			if p.Extensions == nil {
+				p.Extensions = map[string]interface{}{}
+			}
+			p.Extensions[name] = v
+		}
+	}
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	return nil
+}
+
+// MarshalJSON encodes standard members together with extensions.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	members := map[string]interface{}{}
+	for name, value := range p.Extensions {
+		members[name] = value
+	}
+	for name, value := range map[string]string{"type": p.Type, "title": p.Title, "detail": p.Detail, "instance": p.Instance} {
+		if value != "" {
+			members[name] = value
+		}
+	}
+	if p.Status > 0 {
+		members["status"] = p.Status
+	}
+	return json.Marshal(members)
+}
+
+// UnmarshalXML decodes a <problem> element. Child elements other than the standard ones are kept in Extensions.
+func (p *Problem) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	v, err := decodeXmlValue(d)
+	if err != nil {
+		return err
+	}
+	members, _ := v.(map[string]interface{})
+
+	*p = Problem{}
+	for name, value := range members {
+		s, _ := value.(string)
+		switch name {
+		case "type":
+			p.Type = s
+		case "title":
+			p.Title = s
+		case "status":
+			p.Status, _ = strconv.Atoi(strings.TrimSpace(s))
+		case "detail":
+			p.Detail = s
+		case "instance":
+			p.Instance = s
+		default:
+			if p.Extensions == nil {
+				p.Extensions = map[string]interface{}{}
+			}
+			p.Extensions[name] = value
+		}
+	}
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	return nil
+}
+
+// decodeXmlValue decodes the content of an element into a string if it has no child elements,
+// a list if all children are <i> elements, or a map of child elements otherwise.
+func decodeXmlValue(d *xml.Decoder) (interface{}, error) {
+	var text strings.Builder
+	var items []interface{}
+	var members map[string]interface{}
+	onlyItems := true
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.CharData:
+			text.Write(t)
+		case xml.StartElement:
+			v, err := decodeXmlValue(d)
+			if err != nil {
+				return nil, err
+			}
+			if members == nil {
+				members = map[string]interface{}{}
+			}
+			members[t.Name.Local] = v
+			items = append(items, v)
+			onlyItems = onlyItems && t.Name.Local == "i"
+		case xml.EndElement:
+			switch {
+			case members == nil:
+				return text.String(), nil
+			case onlyItems:
+				return items, nil
+			}
+			return members, nil
+		}
+	}
+}
+
+// ProblemDecoder decodes problem details of RFC 7807 in JSON or XML.
+// It decodes into *Problem if it is the output, otherwise it returns the *Problem as an error.
+type ProblemDecoder struct {
+}
+
+// Decode decodes response body if it is a problem details document.
+func (d *ProblemDecoder) Decode(context *ResponseContext, chain *DecoderChain) error {
+	t := mediaType(context.Response.Header)
+	if t != ContentTypeProblemJson && t != ContentTypeProblemXml {
+		return chain.Next()
+	}
+
+	body, err := ioutil.ReadAll(context.Response.Body)
+	if err != nil {
+		return err
+	}
+
+	problem, ok := context.Out.(*Problem)
+	if !ok {
+		problem = &Problem{}
+	}
+	if t == ContentTypeProblemJson {
+		err = json.Unmarshal(body, problem)
+	} else {
+		err = xml.Unmarshal(body, problem)
+	}
+	if err != nil {
+		return err
+	}
+	if problem.Status == 0 {
+		problem.Status = context.Response.StatusCode
+	}
+
+	if ok {
+		return nil
+	}
+	return problem
+}
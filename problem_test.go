@@ -0,0 +1,120 @@
+package sugar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func problemClient(status int, contentType, body string, options ...Option) *Client {
+	return NewClient(append([]Option{
+		WithTransporter(HandlerTransporter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+		}))),
+	}, options...)...)
+}
+
+func TestResponse_Read_ReturnsProblem(t *testing.T) {
+	client := problemClient(http.StatusForbidden, "application/problem+json; charset=utf-8", `{
+		"type": "https://example.com/probs/out-of-credit",
+		"title": "You do not have enough credit.",
+		"status": 403,
+		"detail": "Your current balance is 30, but that costs 50.",
+		"instance": "/account/12345/msgs/abc",
+		"balance": 30,
+		"accounts": ["/account/12345", "/account/67890"]
+	}`)
+
+	var b book
+	resp, err := client.Get(context.Background(), "http://api.example.com/books/1").Read(&b)
+
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	var problem *Problem
+	if assert.True(t, errors.As(err, &problem)) {
+		assert.Equal(t, &Problem{
+			Type:     "https://example.com/probs/out-of-credit",
+			Title:    "You do not have enough credit.",
+			Status:   403,
+			Detail:   "Your current balance is 30, but that costs 50.",
+			Instance: "/account/12345/msgs/abc",
+			Extensions: map[string]interface{}{
+				"balance":  30.0,
+				"accounts": []interface{}{"/account/12345", "/account/67890"},
+			},
+		}, problem)
+		assert.Equal(t, "403 You do not have enough credit.: Your current balance is 30, but that costs 50.", problem.Error())
+	}
+}
+
+func TestResponse_Read_ReturnsXmlProblem(t *testing.T) {
+	client := problemClient(http.StatusForbidden, "application/problem+xml", `<?xml version="1.0" encoding="UTF-8"?>
+<problem xmlns="urn:ietf:rfc:7807">
+  <type>https://example.com/probs/out-of-credit</type>
+  <title>You do not have enough credit.</title>
+  <detail>Your current balance is 30, but that costs 50.</detail>
+  <balance>30</balance>
+  <accounts>
+    <i>/account/12345</i>
+    <i>/account/67890</i>
+  </accounts>
+</problem>`)
+
+	_, err := client.Get(context.Background(), "http://api.example.com/books/1").Read(&book{})
+
+	var problem *Problem
+	if assert.True(t, errors.As(err, &problem)) {
+		assert.Equal(t, "https://example.com/probs/out-of-credit", problem.Type)
+		assert.Equal(t, "You do not have enough credit.", problem.Title)
+		assert.Equal(t, 403, problem.Status)
+		assert.Equal(t, "Your current balance is 30, but that costs 50.", problem.Detail)
+		assert.Equal(t, "30", problem.Extensions["balance"])
+		assert.Equal(t, []interface{}{"/account/12345", "/account/67890"}, problem.Extensions["accounts"])
+	}
+}
+
+func TestResponse_Read_IntoProblem(t *testing.T) {
+	client := problemClient(http.StatusNotFound, "application/problem+json", `{"title":"Not here","status":"404"}`)
+
+	var problem Problem
+	resp, err := client.Get(context.Background(), "http://api.example.com/books/1").Read(&problem)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, Problem{Type: "about:blank", Title: "Not here", Status: http.StatusNotFound}, problem)
+	assert.Equal(t, "404 Not here", problem.Error())
+}
+
+func TestStatusPolicy_DecodesProblem(t *testing.T) {
+	body := `{"type":"https://example.com/probs/invalid","title":"Invalid book","detail":"name is required"}`
+
+	for _, options := range [][]Option{
+		{WithStatusPolicy(Non2xx)},
+		{WithStatusPolicy(Non2xx), WithErrorBody(&bookError{})},
+	} {
+		client := problemClient(http.StatusBadRequest, "application/problem+json", body, options...)
+
+		_, err := client.Post(context.Background(), "http://api.example.com/books", Json{`{}`}).Read(&book{})
+
+		var httpErr *HTTPError
+		var problem *Problem
+		if assert.True(t, errors.As(err, &httpErr)) && assert.True(t, errors.As(err, &problem)) {
+			assert.Same(t, problem, httpErr.Value)
+			assert.Equal(t, "https://example.com/probs/invalid", problem.Type)
+			assert.Equal(t, http.StatusBadRequest, problem.Status)
+			assert.Equal(t, "POST http://api.example.com/books: 400 Bad Request: 400 Invalid book: name is required", err.Error())
+		}
+	}
+}
+
+func TestProblem_MarshalJSON(t *testing.T) {
+	b, err := json.Marshal(Problem{Type: "about:blank", Title: "Oops", Status: 500, Extensions: map[string]interface{}{"trace": "abc"}})
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"about:blank","title":"Oops","status":500,"trace":"abc"}`, string(b))
+}
@@ -0,0 +1,226 @@
+package sugar
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitError is returned by a fail-fast RateLimiter when no token is available.
+type RateLimitError struct {
+	Key        string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded for %s, retry after %s", e.Key, e.RetryAfter)
+}
+
+// RateLimiter is a plugin which applies token bucket limits per key.
+// It must be used as a pointer, e.g. UsePlugin(&RateLimiter{Rate: 10}).
+type RateLimiter struct {
+	// Rate is the number of requests per second. Requests are not limited by tokens if it is not positive.
+	Rate float64
+	// Burst is the size of a bucket, default the ceiling of Rate.
+	Burst int
+	// Key isolates buckets. HostKey is used if it is nil.
+	Key func(c *Context) string
+	// FailFast returns a *RateLimitError instead of waiting for a token.
+	FailFast bool
+	// Adaptive lowers the rate according to X-RateLimit-* and RateLimit-* response headers until the limit resets.
+	Adaptive bool
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+}
+
+type tokenBucket struct {
+	tokens       float64
+	last         time.Time
+	rate         float64
+	rateUntil    time.Time
+	blockedUntil time.Time
+}
+
+// Handle waits for a token, or fails fast, before sending the request.
+func (l *RateLimiter) Handle(c *Context) error {
+	key := HostKey(c)
+	if l.Key != nil {
+		key = l.Key(c)
+	}
+
+	wait, ok := l.reserve(key)
+	if !ok {
+		return &RateLimitError{Key: key, RetryAfter: wait}
+	}
+
+	if wait > 0 {
+		if err := sleep(c.Request.Context(), wait); err != nil {
+			l.cancel(key)
+			return err
+		}
+	}
+
+	err := c.Next()
+	if l.Adaptive && c.Response != nil {
+		l.adapt(key, c.Response.Header)
+	}
+	return err
+}
+
+func (l *RateLimiter) clock() time.Time {
+	if l.now != nil {
+		return l.now()
+	}
+	return time.Now()
+}
+
+func (l *RateLimiter) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return math.Max(1, math.Ceil(l.Rate))
+}
+
+// bucket returns a refilled bucket. It must be called with l.mu held.
+func (l *RateLimiter) bucket(key string, now time.Time) *tokenBucket {
+	if l.buckets == nil {
+		l.buckets = map[string]*tokenBucket{}
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst(), last: now}
+		l.buckets[key] = b
+	}
+
+	if rate := b.effectiveRate(l.Rate, now); rate > 0 {
+		b.tokens = math.Min(l.burst(), b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	b.last = now
+	return b
+}
+
+func (b *tokenBucket) effectiveRate(rate float64, now time.Time) float64 {
+	if now.Before(b.rateUntil) {
+		return b.rate
+	}
+	return rate
+}
+
+// reserve takes a token and returns how long to wait for it.
+// It returns false without taking a token if the limiter fails fast and the wait is positive.
+func (l *RateLimiter) reserve(key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock()
+	b := l.bucket(key, now)
+
+	var wait time.Duration
+	if now.Before(b.blockedUntil) {
+		wait = b.blockedUntil.Sub(now)
+	}
+
+	rate := b.effectiveRate(l.Rate, now)
+	if rate > 0 && b.tokens < 1 {
+		if w := time.Duration((1 - b.tokens) / rate * float64(time.Second)); w > wait {
+			wait = w
+		}
+	}
+
+	if l.FailFast && wait > 0 {
+		return wait, false
+	}
+
+	if rate > 0 {
+		b.tokens--
+	}
+	return wait, true
+}
+
+// cancel gives back a token when the request is canceled while waiting.
+func (l *RateLimiter) cancel(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.buckets[key]; ok && b.effectiveRate(l.Rate, l.clock()) > 0 {
+		b.tokens = math.Min(l.burst(), b.tokens+1)
+	}
+}
+
+// adapt updates a bucket by rate limit headers of a response.
+func (l *RateLimiter) adapt(key string, header http.Header) {
+	remaining, reset, ok := parseRateLimitHeaders(header, l.clock())
+	if !ok {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock()
+	b := l.bucket(key, now)
+	until := now.Add(reset)
+	if remaining <= 0 {
+		b.tokens = 0
+		b.blockedUntil = until
+		return
+	}
+
+	if reset > 0 {
+		rate := float64(remaining) / reset.Seconds()
+		if l.Rate <= 0 || rate < l.Rate {
+			b.rate, b.rateUntil = rate, until
+			b.tokens = math.Min(b.tokens, float64(remaining))
+		}
+	}
+}
+
+// parseRateLimitHeaders reads remaining requests and the duration until the limit resets from
+// X-RateLimit-Remaining/X-RateLimit-Reset, RateLimit-Remaining/RateLimit-Reset or RateLimit headers.
+func parseRateLimitHeaders(header http.Header, now time.Time) (int, time.Duration, bool) {
+	remaining, reset := header.Get("RateLimit-Remaining"), header.Get("RateLimit-Reset")
+	if remaining == "" {
+		remaining, reset = header.Get("X-RateLimit-Remaining"), header.Get("X-RateLimit-Reset")
+	}
+	if remaining == "" {
+		for _, item := range strings.Split(header.Get("RateLimit"), ",") {
+			kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			switch strings.ToLower(kv[0]) {
+			case "remaining", "r":
+				remaining = kv[1]
+			case "reset", "t":
+				reset = kv[1]
+			}
+		}
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(remaining))
+	if err != nil {
+		return 0, 0, false
+	}
+
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(reset), 64)
+	if err != nil {
+		return n, 0, true
+	}
+
+	// Large values are unix timestamps rather than delta seconds.
+	if seconds > 1e9 {
+		d := time.Unix(int64(seconds), 0).Sub(now)
+		if d < 0 {
+			d = 0
+		}
+		return n, d, true
+	}
+	return n, time.Duration(seconds * float64(time.Second)), true
+}
@@ -0,0 +1,109 @@
+package sugar
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter_Fails_Fast(t *testing.T) {
+	l := &RateLimiter{Rate: 1, Burst: 2, FailFast: true}
+	m := &mockTransporter{response: &http.Response{StatusCode: http.StatusOK}}
+
+	assert.Nil(t, newPluginContext(m, http.MethodGet, "http://api.example.com/books", l).Next())
+	assert.Nil(t, newPluginContext(m, http.MethodGet, "http://api.example.com/books", l).Next())
+	err := newPluginContext(m, http.MethodGet, "http://api.example.com/books", l).Next()
+
+	e, ok := err.(*RateLimitError)
+	assert.True(t, ok)
+	assert.Equal(t, "api.example.com", e.Key)
+	assert.True(t, e.RetryAfter > 0)
+	assert.Equal(t, 2, m.count)
+
+	assert.Nil(t, newPluginContext(m, http.MethodGet, "http://other.example.com/books", l).Next())
+}
+
+func TestRateLimiter_Waits_For_Token(t *testing.T) {
+	l := &RateLimiter{Rate: 100, Burst: 1}
+	m := &mockTransporter{response: &http.Response{StatusCode: http.StatusOK}}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, newPluginContext(m, http.MethodGet, "http://api.example.com/books", l).Next())
+	}
+
+	assert.True(t, time.Since(start) >= 15*time.Millisecond)
+	assert.Equal(t, 3, m.count)
+}
+
+func TestRateLimiter_Stops_Waiting_When_Context_Is_Done(t *testing.T) {
+	l := &RateLimiter{Rate: 0.001, Burst: 1}
+	m := &mockTransporter{response: &http.Response{StatusCode: http.StatusOK}}
+	newPluginContext(m, http.MethodGet, "http://api.example.com/books", l).Next()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	c := newPluginContext(m, http.MethodGet, "http://api.example.com/books", l)
+	c.Request = c.Request.WithContext(ctx)
+	err := c.Next()
+
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 1, m.count)
+}
+
+func TestRateLimiter_Adapts_To_Response_Headers(t *testing.T) {
+	now := time.Now()
+	l := &RateLimiter{Adaptive: true, FailFast: true, now: func() time.Time { return now }}
+	m := &mockTransporter{response: &http.Response{StatusCode: http.StatusOK, Header: http.Header{
+		"X-Ratelimit-Remaining": {"0"},
+		"X-Ratelimit-Reset":     {strconv.FormatInt(now.Add(time.Minute).Unix(), 10)},
+	}}}
+
+	assert.Nil(t, newPluginContext(m, http.MethodGet, "http://api.example.com/books", l).Next())
+	err := newPluginContext(m, http.MethodGet, "http://api.example.com/books", l).Next()
+
+	assert.IsType(t, &RateLimitError{}, err)
+	assert.Equal(t, 1, m.count)
+}
+
+func TestRateLimiter_Gives_Back_Adapted_Token_When_Canceled(t *testing.T) {
+	now := time.Now()
+	l := &RateLimiter{Adaptive: true, now: func() time.Time { return now }}
+	m := &mockTransporter{response: &http.Response{StatusCode: http.StatusOK, Header: http.Header{
+		"Ratelimit-Remaining": {"1"},
+		"Ratelimit-Reset":     {"60"},
+	}}}
+	newPluginContext(m, http.MethodGet, "http://api.example.com/books", l).Next()
+	newPluginContext(m, http.MethodGet, "http://api.example.com/books", l).Next()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c := newPluginContext(m, http.MethodGet, "http://api.example.com/books", l)
+	c.Request = c.Request.WithContext(ctx)
+	err := c.Next()
+
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 2, m.count)
+	assert.Equal(t, float64(0), l.buckets["api.example.com"].tokens)
+}
+
+func TestParseRateLimitHeaders(t *testing.T) {
+	now := time.Now()
+
+	remaining, reset, ok := parseRateLimitHeaders(http.Header{"Ratelimit-Remaining": {"10"}, "Ratelimit-Reset": {"5"}}, now)
+	assert.True(t, ok)
+	assert.Equal(t, 10, remaining)
+	assert.Equal(t, 5*time.Second, reset)
+
+	remaining, reset, ok = parseRateLimitHeaders(http.Header{"Ratelimit": {"limit=100, remaining=50, reset=2"}}, now)
+	assert.True(t, ok)
+	assert.Equal(t, 50, remaining)
+	assert.Equal(t, 2*time.Second, reset)
+
+	_, _, ok = parseRateLimitHeaders(http.Header{}, now)
+	assert.False(t, ok)
+}
@@ -2,7 +2,12 @@ package sugar
 
 import (
 	"context"
+	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Client is a entrance to Sugar.
@@ -13,6 +18,20 @@ type Client struct {
 	Decoders    DecoderGroup
 	Plugins     []Plugin
 	Presets     []interface{}
+	// BaseUrl is joined with relative urls of requests, and its query is merged into theirs.
+	BaseUrl string
+	// Header is sent with every request unless presets or params set the same header.
+	Header http.Header
+	// UserAgent is the default User-Agent header.
+	UserAgent string
+	// Timeout limits every request including reading the response body if it is positive.
+	Timeout time.Duration
+	// StatusPolicy turns failed responses into *HTTPError, e.g. Non2xx. Responses are returned as they are if it is nil.
+	StatusPolicy StatusPolicy
+
+	// mu guards fields against Apply, Reset, Use and UsePlugin. Slices are replaced rather than modified
+	// in place, so that a request keeps a consistent snapshot of them.
+	mu sync.RWMutex
 }
 
 var (
@@ -27,6 +46,7 @@ var (
 	Patch      = defaultClient.Patch
 	Delete     = defaultClient.Delete
 	Do         = defaultClient.Do
+	Events     = defaultClient.Events
 	Apply      = defaultClient.Apply
 	Reset      = defaultClient.Reset
 	Use        = defaultClient.Use
@@ -42,11 +62,12 @@ func StandardClient() Transporter {
 }
 
 // New returns a new Client given a transporter, encoders and decoders.
+// Encoders and decoders are copied from the default client, so adding ones to either client does not affect the other.
 func New(factory func() Transporter) *Client {
 	return &Client{
 		Transporter: factory(),
-		Encoders:    defaultClient.Encoders,
-		Decoders:    defaultClient.Decoders,
+		Encoders:    append(EncoderGroup(nil), defaultClient.Encoders...),
+		Decoders:    append(DecoderGroup(nil), defaultClient.Decoders...),
 	}
 }
 
@@ -77,65 +98,191 @@ func (c *Client) Delete(ctx context.Context, rawUrl string, params ...interface{
 
 // Do builds a context and then sends a request via the context.
 func (c *Client) Do(ctx context.Context, method, rawUrl string, params ...interface{}) *Response {
-	context := &Context{
-		ctx:         ctx,
-		Method:      method,
-		RawUrl:      rawUrl,
-		params:      append(c.Presets, params...),
-		Encoders:    c.Encoders,
-		Decoders:    c.Decoders,
-		plugins:     c.Plugins,
-		transporter: c.Transporter,
-	}
-
-	req, err := context.BuildRequest()
+	sc, timeout := c.newContext(ctx, method, rawUrl, params)
+	cancel := func() {}
+	if timeout > 0 {
+		sc.ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	req, err := sc.BuildRequest()
 	if err != nil {
-		return &Response{Error: err, request: req, decoders: c.Decoders}
+		cancel()
+		return &Response{Error: err, request: req, decoders: sc.Decoders}
 	}
 
-	context.Request = req
-	context.reset()
-	if err := context.Next(); err != nil {
-		return &Response{Error: err, request: req, decoders: c.Decoders}
+	sc.Request = req
+	sc.reset()
+	if err := sc.Next(); err != nil {
+		cancel()
+		return &Response{Error: err, request: req, decoders: sc.Decoders}
 	}
 
-	return &Response{Response: *context.Response, Error: nil, request: context.Request, decoders: c.Decoders}
+	if sc.statusPolicy != nil && sc.statusPolicy(sc.Response) {
+		err := newHTTPError(sc.Request, sc.Response, sc.errorBody, sc.Decoders)
+		cancel()
+		return &Response{Response: *sc.Response, Error: err, request: sc.Request, decoders: sc.Decoders}
+	}
+
+	// The timeout also covers reading the body, so the context is released once the body is closed.
+	cancelOnClose(sc.Response, cancel)
+	return &Response{Response: *sc.Response, Error: nil, request: sc.Request, decoders: sc.Decoders}
 }
 
 // NewRequest builds a request via context.
 func (c *Client) NewRequest(ctx context.Context, method, rawUrl string, params ...interface{}) (*http.Request, error) {
-	context := &Context{
-		ctx:      ctx,
-		Method:   method,
-		RawUrl:   rawUrl,
-		params:   append(c.Presets, params...),
-		Encoders: c.Encoders,
+	context, _ := c.newContext(ctx, method, rawUrl, params)
+	return context.BuildRequest()
+}
+
+// ReadRequestBody reads a request body without consuming it, from a copy returned by GetBody,
+// or from the body itself which is then restored and made replayable.
+func ReadRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
 	}
 
-	return context.BuildRequest()
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return ioutil.ReadAll(body)
+	}
+
+	b, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	setBody(req, b)
+	return b, nil
+}
+
+// newContext builds a context from a snapshot of the client, and returns the timeout of the client.
+func (c *Client) newContext(ctx context.Context, method, rawUrl string, params []interface{}) (*Context, time.Duration) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	header := c.Header.Clone()
+	if c.UserAgent != "" {
+		if header == nil {
+			header = http.Header{}
+		}
+		header.Set("User-Agent", c.UserAgent)
+	}
+
+	// A full slice expression makes append allocate, so concurrent requests never write into the presets.
+	presets := c.Presets[:len(c.Presets):len(c.Presets)]
+	return &Context{
+		ctx:          ctx,
+		Method:       method,
+		RawUrl:       resolveUrl(c.BaseUrl, rawUrl),
+		params:       append(presets, params...),
+		header:       header,
+		Encoders:     c.Encoders,
+		Decoders:     c.Decoders,
+		plugins:      c.Plugins,
+		transporter:  c.Transporter,
+		statusPolicy: c.StatusPolicy,
+	}, c.Timeout
+}
+
+// resolveUrl joins a relative url with the base url, and merges their queries.
+// Urls are joined as strings rather than parsed, because they may contain path params such as :id.
+func resolveUrl(baseUrl, rawUrl string) string {
+	if baseUrl == "" || isAbsoluteUrl(rawUrl) {
+		return rawUrl
+	}
+
+	basePath, baseQuery := splitQuery(baseUrl)
+	path, query := splitQuery(rawUrl)
+	if path != "" {
+		basePath = strings.TrimRight(basePath, "/") + "/" + strings.TrimLeft(path, "/")
+	}
+
+	if baseQuery == "" || query == "" {
+		return basePath + joinQuery(baseQuery+query)
+	}
+
+	// Params of the request override the ones with the same name in the base url.
+	merged, err := url.ParseQuery(baseQuery)
+	if err != nil {
+		return basePath + joinQuery(baseQuery+"&"+query)
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return basePath + joinQuery(baseQuery+"&"+query)
+	}
+	for k, v := range values {
+		merged[k] = v
+	}
+	return basePath + joinQuery(merged.Encode())
+}
+
+// isAbsoluteUrl reports whether a url starts with a scheme, e.g. "http://". A "://" in the path or query,
+// e.g. "/login?next=https://example.com", does not make it absolute.
+func isAbsoluteUrl(rawUrl string) bool {
+	i := strings.Index(rawUrl, "://")
+	if i <= 0 {
+		return false
+	}
+	for j, r := range rawUrl[:i] {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		case j > 0 && ('0' <= r && r <= '9' || r == '+' || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func splitQuery(rawUrl string) (string, string) {
+	if i := strings.Index(rawUrl, "?"); i >= 0 {
+		return rawUrl[:i], rawUrl[i+1:]
+	}
+	return rawUrl, ""
+}
+
+func joinQuery(query string) string {
+	if query == "" {
+		return ""
+	}
+	return "?" + query
 }
 
 // Apply attaches params to every following request.
 // Call Reset() to clean.
+// It is safe to be called while requests are being sent.
 func (c *Client) Apply(v ...interface{}) {
-	c.Presets = append(c.Presets, v...)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Presets = append(c.Presets[:len(c.Presets):len(c.Presets)], v...)
 }
 
 // Reset cleans all params added by Apply().
 func (c *Client) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.Presets = nil
 }
 
 // UsePlugin applies plugins.
+// It is safe to be called while requests are being sent, and requests in flight keep the previous plugins.
 func (c *Client) UsePlugin(plugins ...Plugin) {
-	c.Plugins = append(c.Plugins, plugins...)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Plugins = append(c.Plugins[:len(c.Plugins):len(c.Plugins)], plugins...)
 }
 
 // Use applies plugins.
 func (c *Client) Use(plugins ...func(c *Context) error) {
-	for _, p := range plugins {
-		c.Plugins = append(c.Plugins, PluginFunc(p))
+	wrapped := make([]Plugin, len(plugins))
+	for i, p := range plugins {
+		wrapped[i] = PluginFunc(p)
 	}
+	c.UsePlugin(wrapped...)
 }
 
 func init() {
@@ -150,9 +297,11 @@ func init() {
 		&BasicAuthEncoder{},
 		&MultiPartEncoder{},
 		&PlainTextEncoder{},
+		&StructEncoder{},
 	)
 
 	Decoders.Add(
+		&ProblemDecoder{},
 		&JsonDecoder{},
 		&XmlDecoder{},
 		&PlainTextDecoder{},
@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"io/ioutil"
 	"mime"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/h2non/gock.v1"
@@ -642,3 +644,94 @@ func TestPlugin_HandleResponseWithAnErrorCode(t *testing.T) {
 
 	panic("should never reach here")
 }
+
+func TestResolveUrl(t *testing.T) {
+	cases := []struct{ base, raw, expected string }{
+		{"", "http://api.example.com/books", "http://api.example.com/books"},
+		{"http://api.example.com", "/books/:id", "http://api.example.com/books/:id"},
+		{"http://api.example.com/v1/", "/books", "http://api.example.com/v1/books"},
+		{"http://api.example.com/v1", "books", "http://api.example.com/v1/books"},
+		{"http://api.example.com/v1", "", "http://api.example.com/v1"},
+		{"http://api.example.com/v1?key=k", "/books", "http://api.example.com/v1/books?key=k"},
+		{"http://api.example.com/v1?key=k&lang=en", "/books?lang=fr", "http://api.example.com/v1/books?key=k&lang=fr"},
+		{"http://api.example.com/v1?key=k", "?page=1", "http://api.example.com/v1?key=k&page=1"},
+		{"http://api.example.com/v1", "https://other.example.com/books", "https://other.example.com/books"},
+		{"http://api.example.com", "/login?next=https://foo.com", "http://api.example.com/login?next=https://foo.com"},
+		{"http://api.example.com", "/redirect/http://foo.com", "http://api.example.com/redirect/http://foo.com"},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, resolveUrl(c.base, c.raw), "%s + %s", c.base, c.raw)
+	}
+}
+
+func TestClient_BaseUrl_WithUrlInQuery(t *testing.T) {
+	var req *http.Request
+	client := NewClient(WithBaseUrl("http://api.example.com"), WithTransporter(HandlerTransporter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+	}))))
+
+	_, err := client.Get(context.Background(), "/login?next=https://foo.com").Raw()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "api.example.com", req.Host)
+	assert.Equal(t, "https://foo.com", req.URL.Query().Get("next"))
+}
+
+func TestClient_BaseUrlAndDefaults(t *testing.T) {
+	var req *http.Request
+	client := New(func() Transporter {
+		return HandlerTransporter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req = r
+		}))
+	})
+	client.BaseUrl = "http://api.example.com/v1?key=k"
+	client.Header = http.Header{"accept": {"application/json"}, "X-Tenant": {"a"}}
+	client.UserAgent = "books/1.0"
+	client.Apply(Header{"X-Tenant": "b"})
+
+	_, err := client.Get(context.Background(), "/books/:id", Path{"id": 1}, Query{"lang": "en"}).Raw()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/v1/books/1?key=k&lang=en", req.RequestURI)
+	assert.Equal(t, "application/json", req.Header.Get("Accept"))
+	assert.Equal(t, []string{"b"}, req.Header.Values("X-Tenant"))
+	assert.Equal(t, "books/1.0", req.UserAgent())
+	assert.Nil(t, defaultClient.Header)
+	assert.Empty(t, defaultClient.BaseUrl)
+}
+
+func TestClient_Timeout(t *testing.T) {
+	client := New(func() Transporter {
+		return HandlerTransporter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/slow" {
+				<-r.Context().Done()
+				return
+			}
+			w.Write([]byte("sugar"))
+		}))
+	})
+	client.Timeout = 20 * time.Millisecond
+
+	_, err := client.Get(context.Background(), "http://api.example.com/slow").Raw()
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	var text string
+	_, err = client.Get(context.Background(), "http://api.example.com/fast").Read(&text)
+	assert.Nil(t, err)
+	assert.Equal(t, "sugar", text)
+}
+
+func TestReadRequestBody_Restores_Body(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "http://api.example.com/books", ioutil.NopCloser(strings.NewReader("bookA")))
+
+	b, err := ReadRequestBody(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "bookA", string(b))
+
+	b, err = ReadRequestBody(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "bookA", string(b))
+	b, _ = ioutil.ReadAll(req.Body)
+	assert.Equal(t, "bookA", string(b))
+}
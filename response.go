@@ -1,8 +1,10 @@
 package sugar
 
 import (
+	"bytes"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"unsafe"
 )
 
@@ -47,9 +49,37 @@ func (r *Response) ReadBytes() ([]byte, *http.Response, error) {
 	return bytes, resp, err
 }
 
+// CacheStatus returns how the Cache plugin served the response, e.g. CacheHit.
+// It returns an empty string if the response comes from the network.
+func (r *Response) CacheStatus() string {
+	if r.Header == nil {
+		return ""
+	}
+	return r.Header.Get(CacheStatusHeader)
+}
+
 // Close closes response body.
 func (r *Response) Close() {
 	if r != nil && r.Body != nil {
 		r.Body.Close()
 	}
 }
+
+// NewResponse builds an HTTP/1.1 response to a request with an in-memory body,
+// e.g. for a transporter which serves recorded or stubbed responses.
+func NewResponse(req *http.Request, statusCode int, header http.Header, body []byte) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(statusCode) + " " + http.StatusText(statusCode),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -3,6 +3,8 @@ package sugar
 import (
 	"errors"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
 	"testing"
 )
 
@@ -32,3 +34,16 @@ type errorDecoder struct {
 func (d *errorDecoder) Decode(context *ResponseContext, chain *DecoderChain) error {
 	return errors.New("error")
 }
+
+func TestNewResponse(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://api.example.com/books", nil)
+	resp := NewResponse(req, http.StatusNotFound, nil, []byte("not found"))
+
+	assert.Equal(t, "404 Not Found", resp.Status)
+	assert.Equal(t, "HTTP/1.1", resp.Proto)
+	assert.Equal(t, http.Header{}, resp.Header)
+	assert.Equal(t, int64(9), resp.ContentLength)
+	assert.Same(t, req, resp.Request)
+	b, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, "not found", string(b))
+}
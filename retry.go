@@ -0,0 +1,230 @@
+package sugar
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Jitter randomizes delays between attempts.
+type Jitter int
+
+const (
+	// NoJitter uses the exponential delay as it is.
+	NoJitter Jitter = iota
+	// FullJitter picks a delay between 0 and the exponential delay.
+	FullJitter
+	// EqualJitter keeps half of the exponential delay and randomizes the other half.
+	EqualJitter
+	// DecorrelatedJitter picks a delay between the initial delay and three times of the previous one.
+	DecorrelatedJitter
+)
+
+// IdempotencyKey is the header which marks a non-idempotent request as safe to retry.
+const IdempotencyKey = "Idempotency-Key"
+
+// RetryPolicy is a configurable plugin to retry requests.
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts including the first one.
+	Attempts int
+	// Delay is the delay before the first retry.
+	Delay time.Duration
+	// Multiplier multiplies the delay after each retry.
+	Multiplier float64
+	// MaxDelay caps the delay if it is positive. A Retry-After header asking to wait longer than MaxDelay
+	// is not shortened, the policy gives up and returns the response instead.
+	MaxDelay time.Duration
+	Jitter   Jitter
+	// RetryIf reports whether an attempt should be retried. DefaultRetryIf is used if it is nil.
+	RetryIf func(c *Context, err error) bool
+	// RetryNonIdempotent allows retrying POST and PATCH requests without an Idempotency-Key header.
+	RetryNonIdempotent bool
+	// AttemptTimeout limits the duration of each attempt if it is positive.
+	AttemptTimeout time.Duration
+	// OnRetry is called before sleeping for the next attempt.
+	OnRetry func(c *Context, attempt int, err error, delay time.Duration)
+}
+
+// DefaultRetryIf retries on network errors, including timeouts of a transporter or of an attempt,
+// 429 Too Many Requests and 5xx responses. Requests are never retried once the caller's context is done.
+func DefaultRetryIf(c *Context, err error) bool {
+	if err != nil {
+		var netErr net.Error
+		return errors.As(err, &netErr)
+	}
+
+	return c.Response != nil && (c.Response.StatusCode == http.StatusTooManyRequests || c.Response.StatusCode >= http.StatusInternalServerError)
+}
+
+// Handle sends the request and retries it according to the policy.
+func (p RetryPolicy) Handle(c *Context) (err error) {
+	retryIf := p.RetryIf
+	if retryIf == nil {
+		retryIf = DefaultRetryIf
+	}
+
+	index, req := c.index, c.Request
+	ctx := context.Background()
+	if req != nil {
+		ctx = req.Context()
+	}
+	delay, prev := p.Delay, p.Delay
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			if err = rewindBody(req); err != nil {
+				return
+			}
+		}
+
+		var cancel context.CancelFunc = func() {}
+		c.Request = req
+		if p.AttemptTimeout > 0 && req != nil {
+			var attemptCtx context.Context
+			attemptCtx, cancel = context.WithTimeout(ctx, p.AttemptTimeout)
+			c.Request = req.WithContext(attemptCtx)
+		}
+
+		c.index, c.Response = index, nil
+		err = c.Next()
+		// Only the caller's context stops retries, a deadline of the attempt context does not.
+		if attempt >= p.Attempts || ctx.Err() != nil || !p.idempotent(req) || !retryIf(c, err) {
+			cancelOnClose(c.Response, cancel)
+			return
+		}
+
+		var d time.Duration
+		switch p.Jitter {
+		case FullJitter:
+			d = randomDuration(0, delay)
+		case EqualJitter:
+			d = delay/2 + randomDuration(0, delay-delay/2)
+		case DecorrelatedJitter:
+			d = randomDuration(p.Delay, prev*3)
+			prev = d
+		default:
+			d = delay
+		}
+		if p.MaxDelay > 0 && d > p.MaxDelay {
+			d = p.MaxDelay
+		}
+		if retryAfter, ok := parseRetryAfter(c.Response); ok {
+			if p.MaxDelay > 0 && retryAfter > p.MaxDelay {
+				cancelOnClose(c.Response, cancel)
+				return
+			}
+			d = retryAfter
+		}
+
+		discard(c.Response)
+		cancel()
+
+		if p.OnRetry != nil {
+			p.OnRetry(c, attempt, err, d)
+		}
+
+		if err = sleep(ctx, d); err != nil {
+			return
+		}
+
+		if p.Multiplier > 0 {
+			delay = time.Duration(float64(delay) * p.Multiplier)
+		}
+		if p.MaxDelay > 0 && delay > p.MaxDelay {
+			delay = p.MaxDelay
+		}
+	}
+}
+
+func (p RetryPolicy) idempotent(req *http.Request) bool {
+	if p.RetryNonIdempotent || req == nil {
+		return true
+	}
+
+	switch req.Method {
+	case http.MethodPost, http.MethodPatch:
+		return req.Header.Get(IdempotencyKey) != ""
+	}
+	return true
+}
+
+// parseRetryAfter parses the Retry-After header of 429 and 503 responses.
+func parseRetryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func randomDuration(min, max time.Duration) time.Duration {
+	if max <= min {
+		return min
+	}
+	return min + time.Duration(rand.Int63n(int64(max-min)))
+}
+
+// sleep waits for the duration unless the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// discard drains and closes the body of a response so that the connection can be reused.
+func discard(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+}
+
+// cancelOnClose releases the context of an attempt once the response body is closed.
+func cancelOnClose(resp *http.Response, cancel context.CancelFunc) {
+	if resp == nil || resp.Body == nil {
+		cancel()
+		return
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,189 @@
+package sugar
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type closeRecordingBody struct {
+	*strings.Reader
+	closed bool
+}
+
+func (b *closeRecordingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestRetryPolicy_Honors_Retry_After(t *testing.T) {
+	body := &closeRecordingBody{Reader: strings.NewReader("busy")}
+	m := &mockTransporter{responses: []*http.Response{
+		{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"0"}}, Body: body},
+		{StatusCode: http.StatusOK},
+	}}
+	var delays []time.Duration
+	p := RetryPolicy{Attempts: 3, Delay: time.Hour, OnRetry: func(c *Context, attempt int, err error, delay time.Duration) {
+		delays = append(delays, delay)
+	}}
+	c := newPluginContext(m, http.MethodGet, "http://api.example.com/books", p)
+
+	err := c.Next()
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, c.Response.StatusCode)
+	assert.Equal(t, []time.Duration{0}, delays)
+	assert.True(t, body.closed)
+}
+
+func TestRetryPolicy_Gives_Up_When_Retry_After_Exceeds_Max_Delay(t *testing.T) {
+	m := &mockTransporter{responses: []*http.Response{
+		{StatusCode: http.StatusServiceUnavailable, Header: http.Header{"Retry-After": {"120"}}},
+		{StatusCode: http.StatusOK},
+	}}
+	p := RetryPolicy{Attempts: 3, MaxDelay: time.Minute, OnRetry: func(c *Context, attempt int, err error, delay time.Duration) {
+		t.Errorf("unexpected retry after %v", delay)
+	}}
+	c := newPluginContext(m, http.MethodGet, "http://api.example.com/books", p)
+
+	err := c.Next()
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, c.Response.StatusCode)
+	assert.Len(t, m.requests, 1)
+}
+
+func TestRetryPolicy_Not_Retry_Post_Without_Idempotency_Key(t *testing.T) {
+	m := &mockTransporter{responses: []*http.Response{{StatusCode: http.StatusBadGateway}}}
+	c := newPluginContext(m, http.MethodPost, "http://api.example.com/books", RetryPolicy{Attempts: 3})
+
+	c.Next()
+
+	assert.Equal(t, 1, len(m.requests))
+}
+
+func TestRetryPolicy_Retry_Post_With_Idempotency_Key(t *testing.T) {
+	m := &mockTransporter{responses: []*http.Response{{StatusCode: http.StatusBadGateway}}}
+	c := newPluginContext(m, http.MethodPost, "http://api.example.com/books", RetryPolicy{Attempts: 3})
+	c.Request.Header.Set(IdempotencyKey, "key")
+
+	c.Next()
+
+	assert.Equal(t, 3, len(m.requests))
+}
+
+func TestRetryPolicy_Uses_Custom_Predicate(t *testing.T) {
+	m := &mockTransporter{responses: []*http.Response{{StatusCode: http.StatusNotFound}, {StatusCode: http.StatusOK}}}
+	p := RetryPolicy{Attempts: 3, RetryIf: func(c *Context, err error) bool {
+		return c.Response.StatusCode == http.StatusNotFound
+	}}
+	c := newPluginContext(m, http.MethodGet, "http://api.example.com/books", p)
+
+	c.Next()
+
+	assert.Equal(t, 2, len(m.requests))
+	assert.Equal(t, http.StatusOK, c.Response.StatusCode)
+}
+
+func TestRetryPolicy_Stops_When_Context_Is_Done(t *testing.T) {
+	m := &mockTransporter{error: &net.OpError{}}
+	c := newPluginContext(m, http.MethodGet, "http://api.example.com/books", RetryPolicy{Attempts: 3, Delay: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	c.Request = c.Request.WithContext(ctx)
+
+	err := c.Next()
+
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 1, m.count)
+}
+
+func TestRetryPolicy_Sets_Attempt_Timeout(t *testing.T) {
+	m := &mockTransporter{responses: []*http.Response{{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("ok"))}}}
+	c := newPluginContext(m, http.MethodGet, "http://api.example.com/books", RetryPolicy{Attempts: 1, AttemptTimeout: time.Minute})
+
+	c.Next()
+
+	_, ok := m.requests[0].Context().Deadline()
+	assert.True(t, ok)
+	assert.Nil(t, m.requests[0].Context().Err())
+	c.Response.Body.Close()
+	assert.NotNil(t, m.requests[0].Context().Err())
+}
+
+func TestRetryPolicy_Applies_Jitter(t *testing.T) {
+	for _, jitter := range []Jitter{FullJitter, EqualJitter, DecorrelatedJitter} {
+		m := &mockTransporter{responses: []*http.Response{{StatusCode: http.StatusServiceUnavailable}}}
+		var delays []time.Duration
+		p := RetryPolicy{Attempts: 4, Delay: time.Millisecond, Multiplier: 2, MaxDelay: 3 * time.Millisecond, Jitter: jitter,
+			OnRetry: func(c *Context, attempt int, err error, delay time.Duration) {
+				delays = append(delays, delay)
+			}}
+		c := newPluginContext(m, http.MethodGet, "http://api.example.com/books", p)
+
+		c.Next()
+
+		assert.Equal(t, 4, len(m.requests))
+		for _, d := range delays {
+			assert.True(t, d >= 0 && d <= 3*time.Millisecond)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	d, ok := parseRetryAfter(&http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{"Retry-After": {"2"}}})
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Second, d)
+
+	d, ok = parseRetryAfter(&http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{"Retry-After": {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}}})
+	assert.True(t, ok)
+	assert.True(t, d > 59*time.Minute)
+
+	_, ok = parseRetryAfter(&http.Response{StatusCode: http.StatusInternalServerError, Header: http.Header{"Retry-After": {"2"}}})
+	assert.False(t, ok)
+}
+
+func TestRetryer_Retries_Transporter_Timeout(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+	client := New(func() Transporter { return &http.Client{Timeout: 50 * time.Millisecond} })
+	client.UsePlugin(PluginFunc(Retryer(3, time.Millisecond, 1, time.Millisecond)))
+
+	_, err := client.Get(context.Background(), server.URL).Raw()
+
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+}
+
+func TestRetryPolicy_Retries_Attempt_Timeout(t *testing.T) {
+	var attempts int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	c := newPluginContext(HandlerTransporter(handler), http.MethodGet, "http://api.example.com/books", RetryPolicy{Attempts: 3, AttemptTimeout: 50 * time.Millisecond})
+
+	err := c.Next()
+
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+	assert.Equal(t, http.StatusOK, c.Response.StatusCode)
+}
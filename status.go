@@ -0,0 +1,123 @@
+package sugar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// StatusPolicy reports whether a response is a failure, which is then returned as *HTTPError.
+type StatusPolicy func(resp *http.Response) bool
+
+// Non2xx reports responses whose status codes are not 2xx as failures.
+func Non2xx(resp *http.Response) bool {
+	return resp.StatusCode < 200 || resp.StatusCode > 299
+}
+
+// ErrorBody registers the type of error bodies, e.g. ErrorBody{&ApiError{}}.
+// Bodies of failed responses are decoded into a new value of the type and kept in HTTPError.Value.
+// It is passed as a param of a request, or applied to every request of a client via Apply or WithErrorBody.
+type ErrorBody struct {
+	Value interface{}
+}
+
+const (
+	// maxErrorBodySize limits how much of a failed response is read to decode the error body.
+	maxErrorBodySize = 1 << 20
+	// errorBodySnippetSize limits the raw body kept in HTTPError.
+	errorBodySnippetSize = 4096
+)
+
+// HTTPError is returned for a response which the StatusPolicy of the client reports as a failure.
+// The error body decoded into the type registered by ErrorBody is kept in Value, and is also
+// the wrapped error if it implements error, so that errors.As finds it.
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	// Body is the beginning of the raw response body.
+	Body   []byte
+	Method string
+	Url    string
+	// Value is the decoded error body, or nil if no ErrorBody is registered or decoding fails.
+	Value interface{}
+}
+
+func (e *HTTPError) Error() string {
+	msg := fmt.Sprintf("%s %s: %s", e.Method, e.Url, e.Status)
+	if err, ok := e.Value.(error); ok {
+		return msg + ": " + err.Error()
+	}
+	if body := strings.TrimSpace(string(e.Body)); body != "" && len(body) <= 256 && !isBinary(e.Header, e.Body, false) {
+		return msg + ": " + body
+	}
+	return msg
+}
+
+func (e *HTTPError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// newHTTPError reads and closes the body of a failed response, and decodes it into a new value of the error body type.
+func newHTTPError(req *http.Request, resp *http.Response, errorBody interface{}, decoders DecoderGroup) *HTTPError {
+	var body []byte
+	if resp.Body != nil {
+		body, _ = ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		resp.Body.Close()
+	}
+
+	e := &HTTPError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Body:       body,
+		Method:     req.Method,
+		Url:        redactedUrl(req.URL),
+	}
+	if len(e.Body) > errorBodySnippetSize {
+		e.Body = e.Body[:errorBodySnippetSize]
+	}
+	if e.Status == "" {
+		e.Status = fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+
+	if len(body) == 0 {
+		return e
+	}
+	if errorBody == nil {
+		// Problem details are decoded even if no error body type is registered.
+		if t := mediaType(resp.Header); t != ContentTypeProblemJson && t != ContentTypeProblemXml {
+			return e
+		}
+		errorBody = &Problem{}
+	}
+	t := reflect.TypeOf(errorBody)
+	isPtr := t.Kind() == reflect.Ptr
+	if isPtr {
+		t = t.Elem()
+	}
+	v := reflect.New(t)
+
+	decoded := *resp
+	decoded.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err := decoders.Decode(&decoded, v.Interface()); err != nil {
+		// ProblemDecoder returns problem details as an error if they are not decoded into *Problem.
+		var problem *Problem
+		if errors.As(err, &problem) {
+			e.Value = problem
+		}
+		return e
+	}
+	if isPtr {
+		e.Value = v.Interface()
+	} else {
+		e.Value = v.Elem().Interface()
+	}
+	return e
+}
@@ -0,0 +1,216 @@
+package sugar
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+	"strings"
+)
+
+// Stream iterates values of a response one at a time without reading the whole body into memory.
+// NDJSON and JSON Lines bodies are iterated line by line, other bodies are expected to be JSON arrays.
+// Iteration stops after an error is yielded, and the body is closed when iteration stops, including by break.
+// The sequence can be iterated only once, and the body is left open if it is never iterated.
+func Stream[T any](r *Response) iter.Seq2[T, error] {
+	if r.Error == nil && isLines(mediaType(r.Header)) {
+		return StreamLines[T](r)
+	}
+	return StreamAt[T](r, "")
+}
+
+// StreamAt iterates elements of the JSON array at a JSON pointer of RFC 6901, e.g. "/data/items".
+// An empty pointer refers to the whole document.
+func StreamAt[T any](r *Response, pointer string) iter.Seq2[T, error] {
+	return stream[T](r, func(body io.Reader, yield func(T, error) bool) {
+		dec := json.NewDecoder(body)
+		if err := seekJsonPointer(dec, pointer); err != nil {
+			var v T
+			yield(v, err)
+			return
+		}
+		if err := expectDelim(dec, '['); err != nil {
+			if pointer != "" {
+				err = fmt.Errorf("json pointer %q: %w", pointer, err)
+			}
+			var v T
+			yield(v, err)
+			return
+		}
+
+		for dec.More() {
+			var v T
+			if err := dec.Decode(&v); err != nil {
+				yield(v, err)
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			var v T
+			yield(v, err)
+		}
+	})
+}
+
+// StreamLines iterates values of an NDJSON or JSON Lines body, one JSON value per line. Blank lines are skipped.
+func StreamLines[T any](r *Response) iter.Seq2[T, error] {
+	return stream[T](r, func(body io.Reader, yield func(T, error) bool) {
+		reader := bufio.NewReader(body)
+		for n := 1; ; n++ {
+			line, err := reader.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				var v T
+				yield(v, err)
+				return
+			}
+
+			if line = bytes.TrimSpace(line); len(line) > 0 {
+				var v T
+				if err := json.Unmarshal(line, &v); err != nil {
+					yield(v, fmt.Errorf("line %d: %w", n, err))
+					return
+				}
+				if !yield(v, nil) {
+					return
+				}
+			}
+			if err == io.EOF {
+				return
+			}
+		}
+	})
+}
+
+// stream yields the error of the response, or the problem details it carries, instead of iterating the body.
+func stream[T any](r *Response, iterate func(body io.Reader, yield func(T, error) bool)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		defer r.Close()
+
+		resp, err := r.Raw()
+		if err == nil {
+			if t := mediaType(resp.Header); t == ContentTypeProblemJson || t == ContentTypeProblemXml {
+				err = NewDecoderChain(&ResponseContext{Response: resp}, &ProblemDecoder{}).Next()
+			}
+		}
+		if err != nil {
+			var v T
+			yield(v, err)
+			return
+		}
+
+		iterate(resp.Body, yield)
+	}
+}
+
+func isLines(t string) bool {
+	return t == ContentTypeNdjson || t == ContentTypeJsonLines
+}
+
+// isStreaming reports whether a media type is a stream which is consumed as the server sends it.
+func isStreaming(t string) bool {
+	return isLines(t) || t == ContentTypeEventStream
+}
+
+// seekJsonPointer advances the decoder to the value referred to by a JSON pointer, skipping other values token by token.
+func seekJsonPointer(dec *json.Decoder, pointer string) error {
+	if pointer == "" {
+		return nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return fmt.Errorf("invalid json pointer %q", pointer)
+	}
+
+	for _, segment := range strings.Split(pointer[1:], "/") {
+		segment = strings.NewReplacer("~1", "/", "~0", "~").Replace(segment)
+
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'):
+			if err := seekMember(dec, segment); err != nil {
+				return fmt.Errorf("json pointer %q: %w", pointer, err)
+			}
+		case json.Delim('['):
+			if err := seekElement(dec, segment); err != nil {
+				return fmt.Errorf("json pointer %q: %w", pointer, err)
+			}
+		default:
+			return fmt.Errorf("json pointer %q: %q is not in an object or array", pointer, segment)
+		}
+	}
+	return nil
+}
+
+func seekMember(dec *json.Decoder, name string) error {
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if token == name {
+			return nil
+		}
+		if err := skipJsonValue(dec); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("member %q not found", name)
+}
+
+func seekElement(dec *json.Decoder, index string) error {
+	n, err := strconv.Atoi(index)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid array index %q", index)
+	}
+	for i := 0; dec.More(); i++ {
+		if i == n {
+			return nil
+		}
+		if err := skipJsonValue(dec); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("index %d out of range", n)
+}
+
+// skipJsonValue consumes a value without decoding it, so that skipped values are not kept in memory.
+func skipJsonValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %v, got %v", delim, token)
+	}
+	return nil
+}
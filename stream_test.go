@@ -0,0 +1,158 @@
+package sugar
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
+
+func streamResponse(contentType, body string) (*Response, *closeRecorder) {
+	recorder := &closeRecorder{Reader: strings.NewReader(body)}
+	return &Response{Response: http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {contentType}},
+		Body:       recorder,
+	}}, recorder
+}
+
+func collect[T any](seq func(func(T, error) bool)) ([]T, error) {
+	var values []T
+	for v, err := range seq {
+		if err != nil {
+			return values, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func TestStream_JsonArray(t *testing.T) {
+	resp, body := streamResponse("application/json", `[{"name":"bookA"}, {"name":"bookB"}]`)
+
+	books, err := collect(Stream[book](resp))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []book{{Name: "bookA"}, {Name: "bookB"}}, books)
+	assert.True(t, body.closed)
+}
+
+func TestStreamAt_JsonPointer(t *testing.T) {
+	resp, _ := streamResponse("application/json", `{
+		"meta": {"skipped": [1, {"a": [2, 3]}], "total": 2},
+		"data": [
+			{"items": []},
+			{"id": "a/b", "items": [{"name": "bookA"}, {"name": "bookB"}]}
+		]
+	}`)
+
+	books, err := collect(StreamAt[book](resp, "/data/1/items"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []book{{Name: "bookA"}, {Name: "bookB"}}, books)
+}
+
+func TestStreamAt_Errors(t *testing.T) {
+	tests := []struct {
+		pointer string
+		body    string
+		error   string
+	}{
+		{"/data", `{"meta": {}}`, `json pointer "/data": member "data" not found`},
+		{"/data/2", `{"data": [[], []]}`, `json pointer "/data/2": index 2 out of range`},
+		{"/data", `{"data": {"name": "bookA"}}`, `json pointer "/data": expected [, got {`},
+		{"data", `{"data": []}`, `invalid json pointer "data"`},
+		{"", `{"name": "bookA"}`, `expected [, got {`},
+		{"", `[{"name": "bookA"}`, `unexpected end of JSON input`},
+	}
+
+	for _, test := range tests {
+		resp, body := streamResponse("application/json", test.body)
+
+		_, err := collect(StreamAt[book](resp, test.pointer))
+
+		if assert.Error(t, err, test.pointer) {
+			assert.Equal(t, test.error, err.Error())
+		}
+		assert.True(t, body.closed)
+	}
+}
+
+func TestStream_Lines(t *testing.T) {
+	for _, contentType := range []string{"application/x-ndjson", "application/jsonl; charset=utf-8"} {
+		resp, _ := streamResponse(contentType, "{\"name\":\"bookA\"}\r\n\n{\"name\":\"bookB\"}")
+
+		books, err := collect(Stream[book](resp))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []book{{Name: "bookA"}, {Name: "bookB"}}, books)
+	}
+}
+
+func TestStreamLines_ReportsLine(t *testing.T) {
+	resp, _ := streamResponse("text/plain", "{\"name\":\"bookA\"}\n\n{\"name\":\n")
+
+	books, err := collect(StreamLines[book](resp))
+
+	assert.Equal(t, []book{{Name: "bookA"}}, books)
+	if assert.Error(t, err) {
+		assert.True(t, strings.HasPrefix(err.Error(), "line 3: "), err.Error())
+	}
+}
+
+func TestStream_BreakClosesBody(t *testing.T) {
+	resp, body := streamResponse("application/json", `[{"name":"bookA"}, {"name":"bookB"}, {"name":`)
+
+	for b, err := range Stream[book](resp) {
+		assert.NoError(t, err)
+		assert.Equal(t, "bookA", b.Name)
+		break
+	}
+	assert.True(t, body.closed)
+}
+
+func TestStream_Errors(t *testing.T) {
+	requestErr := errors.New("connection refused")
+	_, err := collect(Stream[book](&Response{Error: requestErr}))
+	assert.Same(t, requestErr, err)
+
+	resp, body := streamResponse("application/problem+json", `{"title":"Export failed","status":503}`)
+	_, err = collect(Stream[book](resp))
+	var problem *Problem
+	if assert.True(t, errors.As(err, &problem)) {
+		assert.Equal(t, "Export failed", problem.Title)
+	}
+	assert.True(t, body.closed)
+}
+
+func TestStream_FromClient(t *testing.T) {
+	client := NewClient(WithTransporter(HandlerTransporter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, name := range []string{"bookA", "bookB", "bookC"} {
+			w.Write([]byte(`{"name":"` + name + `"}` + "\n"))
+		}
+	}))))
+
+	var names []string
+	for b, err := range Stream[book](client.Get(context.Background(), "http://api.example.com/books/export")) {
+		if !assert.NoError(t, err) {
+			break
+		}
+		names = append(names, b.Name)
+	}
+	assert.Equal(t, []string{"bookA", "bookB", "bookC"}, names)
+}
@@ -0,0 +1,366 @@
+// Package cassette records exchanges of a Transporter into cassette files and replays them in tests.
+package cassette
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pojozhang/sugar"
+	"gopkg.in/yaml.v3"
+)
+
+// Mode decides whether a Recorder sends requests or replays recorded interactions.
+type Mode int
+
+const (
+	// Replay serves requests from the cassette only and fails if an interaction is missing.
+	Replay Mode = iota
+	// Record sends every request and overwrites the cassette.
+	Record
+	// RecordIfMissing replays recorded interactions and records the missing ones.
+	RecordIfMissing
+)
+
+// ErrInteractionNotFound is returned in Replay mode when no interaction matches a request.
+var ErrInteractionNotFound = errors.New("cassette: interaction not found")
+
+// Cassette is the content of a cassette file.
+type Cassette struct {
+	Version      int            `json:"version" yaml:"version"`
+	Interactions []*Interaction `json:"interactions" yaml:"interactions"`
+}
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  Request  `json:"request" yaml:"request"`
+	Response Response `json:"response" yaml:"response"`
+}
+
+// Request is a recorded request.
+type Request struct {
+	Method string      `json:"method" yaml:"method"`
+	URL    string      `json:"url" yaml:"url"`
+	Header http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+	Body   string      `json:"body,omitempty" yaml:"body,omitempty"`
+}
+
+// Response is a recorded response. Binary bodies are base64 encoded.
+type Response struct {
+	StatusCode   int         `json:"status_code" yaml:"status_code"`
+	Header       http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+	Body         string      `json:"body,omitempty" yaml:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty" yaml:"body_encoding,omitempty"`
+}
+
+// Matcher reports whether a request matches a recorded one. body is the request body.
+type Matcher func(req *http.Request, body []byte, recorded Request) bool
+
+// Hook modifies an interaction before it is kept, e.g. to scrub secrets.
+type Hook func(i *Interaction)
+
+// Recorder is a sugar.Transporter which records and replays interactions.
+// Interactions are kept in memory and written to Path by Stop.
+type Recorder struct {
+	// Path is the cassette file. It is encoded as JSON if the extension is .json, otherwise YAML.
+	Path string
+	Mode Mode
+	// Transporter sends requests in Record and RecordIfMissing modes, default http.DefaultClient.
+	Transporter sugar.Transporter
+	// Matcher matches requests with recorded ones, default DefaultMatcher.
+	Matcher Matcher
+	// Hooks are applied to new interactions before they are kept. Responses returned to the caller are untouched.
+	Hooks []Hook
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     map[*Interaction]bool
+	changed  bool
+}
+
+// DefaultMatcher matches method, url without the query, and the query ignoring the order of params.
+var DefaultMatcher = All(MatchMethod, MatchURL, MatchQuery)
+
+// New returns a Recorder of a cassette file. The file must exist in Replay mode.
+func New(path string, mode Mode) (*Recorder, error) {
+	r := &Recorder{Path: path, Mode: mode}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Recorder) load() error {
+	r.cassette, r.used = &Cassette{Version: 1}, map[*Interaction]bool{}
+	if r.Mode == Record {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(r.Path)
+	if errors.Is(err, os.ErrNotExist) && r.Mode == RecordIfMissing {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if isJson(r.Path) {
+		return json.Unmarshal(b, r.cassette)
+	}
+	return yaml.Unmarshal(b, r.cassette)
+}
+
+// Do replays the interaction matching the request, or sends and records the request according to the mode.
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	body, err := sugar.ReadRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	if r.cassette == nil {
+		if err := r.load(); err != nil {
+			r.mu.Unlock()
+			return nil, err
+		}
+	}
+	if r.Mode != Record {
+		if i := r.find(req, body); i != nil {
+			r.mu.Unlock()
+			return i.Response.response(req)
+		}
+	}
+	r.mu.Unlock()
+
+	if r.Mode == Replay {
+		return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, req.Method, req.URL)
+	}
+	return r.record(req, body)
+}
+
+// find returns the first unused matching interaction, or the last matching one if all of them are used.
+func (r *Recorder) find(req *http.Request, body []byte) *Interaction {
+	matcher := r.Matcher
+	if matcher == nil {
+		matcher = DefaultMatcher
+	}
+
+	var match *Interaction
+	for _, i := range r.cassette.Interactions {
+		if !matcher(req, body, i.Request) {
+			continue
+		}
+		match = i
+		if !r.used[i] {
+			break
+		}
+	}
+	if match != nil {
+		r.used[match] = true
+	}
+	return match
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	transporter := r.Transporter
+	if transporter == nil {
+		transporter = http.DefaultClient
+	}
+
+	resp, err := transporter.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	i := &Interaction{
+		Request:  Request{Method: req.Method, URL: req.URL.String(), Header: req.Header.Clone(), Body: string(body)},
+		Response: Response{StatusCode: resp.StatusCode, Header: resp.Header.Clone()},
+	}
+	if utf8.Valid(respBody) {
+		i.Response.Body = string(respBody)
+	} else {
+		i.Response.Body, i.Response.BodyEncoding = base64.StdEncoding.EncodeToString(respBody), "base64"
+	}
+	for _, hook := range r.Hooks {
+		hook(i)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, i)
+	r.used[i] = true
+	r.changed = true
+	return resp, nil
+}
+
+// Stop writes the cassette file if new interactions are recorded.
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.changed {
+		return nil
+	}
+
+	var b []byte
+	var err error
+	if isJson(r.Path) {
+		b, err = json.MarshalIndent(r.cassette, "", "  ")
+	} else {
+		b, err = yaml.Marshal(r.cassette)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.Path), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(r.Path, b, 0644); err != nil {
+		return err
+	}
+	r.changed = false
+	return nil
+}
+
+func isJson(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
+
+func (r Response) response(req *http.Request) (*http.Response, error) {
+	body := []byte(r.Body)
+	if r.BodyEncoding == "base64" {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(r.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	return sugar.NewResponse(req, r.StatusCode, r.Header.Clone(), body), nil
+}
+
+// All matches a request if all of the matchers match it.
+func All(matchers ...Matcher) Matcher {
+	return func(req *http.Request, body []byte, recorded Request) bool {
+		for _, m := range matchers {
+			if !m(req, body, recorded) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchMethod compares methods.
+func MatchMethod(req *http.Request, body []byte, recorded Request) bool {
+	return strings.EqualFold(req.Method, recorded.Method)
+}
+
+// MatchURL compares urls without queries.
+func MatchURL(req *http.Request, body []byte, recorded Request) bool {
+	u, err := url.Parse(recorded.URL)
+	if err != nil {
+		return false
+	}
+	actual := *req.URL
+	actual.RawQuery, u.RawQuery = "", ""
+	return actual.String() == u.String()
+}
+
+// MatchQuery compares queries ignoring the order of params. Values scrubbed by ScrubQuery match any value.
+func MatchQuery(req *http.Request, body []byte, recorded Request) bool {
+	u, err := url.Parse(recorded.URL)
+	if err != nil {
+		return false
+	}
+
+	expected, actual := u.Query(), req.URL.Query()
+	if len(expected) != len(actual) {
+		return false
+	}
+	for name, values := range expected {
+		if len(values) == 1 && values[0] == sugar.Redacted {
+			if _, ok := actual[name]; !ok {
+				return false
+			}
+			continue
+		}
+		if !reflect.DeepEqual(values, actual[name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchBody compares bodies. JSON bodies are compared by value.
+func MatchBody(req *http.Request, body []byte, recorded Request) bool {
+	if string(body) == recorded.Body {
+		return true
+	}
+
+	var a, b interface{}
+	if json.Unmarshal(body, &a) != nil || json.Unmarshal([]byte(recorded.Body), &b) != nil {
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// MatchHeaders compares the values of headers.
+func MatchHeaders(names ...string) Matcher {
+	return func(req *http.Request, body []byte, recorded Request) bool {
+		for _, name := range names {
+			if !reflect.DeepEqual(req.Header.Values(name), recorded.Header.Values(name)) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// ScrubHeaders replaces values of request and response headers with "REDACTED".
+func ScrubHeaders(names ...string) Hook {
+	return func(i *Interaction) {
+		for _, name := range names {
+			for _, header := range []http.Header{i.Request.Header, i.Response.Header} {
+				if _, ok := header[http.CanonicalHeaderKey(name)]; ok {
+					header.Set(name, sugar.Redacted)
+				}
+			}
+		}
+	}
+}
+
+// ScrubQuery replaces values of query params in the request url with "REDACTED".
+func ScrubQuery(names ...string) Hook {
+	return func(i *Interaction) {
+		u, err := url.Parse(i.Request.URL)
+		if err != nil {
+			return
+		}
+		query := u.Query()
+		for _, name := range names {
+			if _, ok := query[name]; ok {
+				query.Set(name, sugar.Redacted)
+			}
+		}
+		u.RawQuery = query.Encode()
+		i.Request.URL = u.String()
+	}
+}
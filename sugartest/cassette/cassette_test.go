@@ -0,0 +1,109 @@
+package cassette
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pojozhang/sugar"
+	"github.com/stretchr/testify/assert"
+)
+
+func newServer(calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Write([]byte(r.Method + " " + r.URL.Query().Get("name") + " " + string(b)))
+	}))
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	for _, name := range []string{"books.yaml", "books.json"} {
+		t.Run(name, func(t *testing.T) {
+			var calls int32
+			server := newServer(&calls)
+			defer server.Close()
+			path := filepath.Join(t.TempDir(), "fixtures", name)
+
+			recorder, err := New(path, Record)
+			assert.Nil(t, err)
+			recorder.Hooks = []Hook{ScrubHeaders("Authorization", "Set-Cookie"), ScrubQuery("key")}
+			client := sugar.New(func() sugar.Transporter { return recorder })
+
+			_, b, err := readString(client.Get(context.Background(), server.URL+"/books?name=a&key=k1", sugar.Header{"Authorization": "token"}))
+			assert.Nil(t, err)
+			assert.Equal(t, "GET a", b)
+			resp, b, err := readString(client.Post(context.Background(), server.URL+"/books", sugar.Json{Payload: `{"name":"a"}`}))
+			assert.Nil(t, err)
+			assert.Equal(t, `POST  {"name":"a"}`, b)
+			assert.Equal(t, "session=secret", resp.Header.Get("Set-Cookie"))
+			assert.Nil(t, recorder.Stop())
+
+			content, _ := ioutil.ReadFile(path)
+			assert.NotContains(t, string(content), "secret")
+			assert.NotContains(t, string(content), "k1")
+			assert.NotContains(t, string(content), "token")
+
+			server.Close()
+			recorder, err = New(path, Replay)
+			assert.Nil(t, err)
+			recorder.Matcher = All(DefaultMatcher, MatchBody)
+			client = sugar.New(func() sugar.Transporter { return recorder })
+
+			_, b, err = readString(client.Get(context.Background(), server.URL+"/books?key=k2&name=a"))
+			assert.Nil(t, err)
+			assert.Equal(t, "GET a", b)
+			_, b, err = readString(client.Post(context.Background(), server.URL+"/books", sugar.Json{Payload: `{ "name": "a" }`}))
+			assert.Nil(t, err)
+			assert.Equal(t, `POST  {"name":"a"}`, b)
+
+			_, err = client.Post(context.Background(), server.URL+"/books", sugar.Json{Payload: `{"name":"b"}`}).Raw()
+			assert.True(t, errors.Is(err, ErrInteractionNotFound))
+			assert.Equal(t, int32(2), calls)
+		})
+	}
+}
+
+func TestRecordIfMissing(t *testing.T) {
+	var calls int32
+	server := newServer(&calls)
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "books.yaml")
+
+	for i := 0; i < 2; i++ {
+		recorder, err := New(path, RecordIfMissing)
+		assert.Nil(t, err)
+		client := sugar.New(func() sugar.Transporter { return recorder })
+		for _, name := range []string{"a", "b", "a"} {
+			_, b, err := readString(client.Get(context.Background(), server.URL+"/books", sugar.Query{"name": name}))
+			assert.Nil(t, err)
+			assert.Equal(t, "GET "+name, b)
+		}
+		assert.Nil(t, recorder.Stop())
+	}
+	assert.Equal(t, int32(2), calls)
+}
+
+func TestReplayWithoutCassette(t *testing.T) {
+	_, err := New(filepath.Join(t.TempDir(), "missing.yaml"), Replay)
+	assert.NotNil(t, err)
+}
+
+func TestMatchHeaders(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://api.example.com", nil)
+	req.Header.Set("Accept", "application/json")
+	assert.True(t, MatchHeaders("Accept")(req, nil, Request{Header: http.Header{"Accept": {"application/json"}}}))
+	assert.False(t, MatchHeaders("Accept")(req, nil, Request{Header: http.Header{"Accept": {"application/xml"}}}))
+}
+
+func readString(r *sugar.Response) (*http.Response, string, error) {
+	b, resp, err := r.ReadBytes()
+	return resp, strings.TrimSpace(string(b)), err
+}
@@ -0,0 +1,317 @@
+// Package sugartest provides helpers to test code built on sugar clients.
+package sugartest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pojozhang/sugar"
+)
+
+// MockTransporter is a sugar.Transporter which responds according to expectations.
+// Expectations are verified and unmatched requests are reported when the test finishes.
+type MockTransporter struct {
+	t            testing.TB
+	mu           sync.Mutex
+	expectations []*Expectation
+	unmatched    []string
+}
+
+// NewMockTransporter returns a MockTransporter which verifies expectations at t.Cleanup.
+func NewMockTransporter(t testing.TB) *MockTransporter {
+	m := &MockTransporter{t: t}
+	t.Cleanup(m.Verify)
+	return m
+}
+
+// Expectation describes requests and the responses to them.
+type Expectation struct {
+	mock      *MockTransporter
+	method    string
+	pattern   string
+	query     map[string]string
+	header    map[string]string
+	body      *string
+	jsonBody  interface{}
+	hasJson   bool
+	times     int
+	calls     int
+	responses []*MockResponse
+}
+
+// MockResponse is a response, or an error, returned for a matched request.
+type MockResponse struct {
+	expectation *Expectation
+	status      int
+	header      http.Header
+	body        []byte
+	err         error
+}
+
+// On adds an expectation of requests by method and url pattern.
+// The pattern is a path, or a url with scheme and host, in which ":name" matches a segment and a trailing "*" matches the rest.
+func (m *MockTransporter) On(method, pattern string) *Expectation {
+	e := &Expectation{mock: m, method: method, pattern: pattern, query: map[string]string{}, header: map[string]string{}}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expectations = append(m.expectations, e)
+	return e
+}
+
+// WithQuery requires a query param.
+func (e *Expectation) WithQuery(name, value string) *Expectation {
+	e.query[name] = value
+	return e
+}
+
+// WithHeader requires a header.
+func (e *Expectation) WithHeader(name, value string) *Expectation {
+	e.header[http.CanonicalHeaderKey(name)] = value
+	return e
+}
+
+// WithBody requires the request body to be equal to body.
+func (e *Expectation) WithBody(body string) *Expectation {
+	e.body = &body
+	return e
+}
+
+// WithJsonBody requires the request body to be JSON equal to v after both are decoded.
+func (e *Expectation) WithJsonBody(v interface{}) *Expectation {
+	e.jsonBody, e.hasJson = v, true
+	return e
+}
+
+// Times requires the expectation to be matched exactly n times. It is matched at least once by default.
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+// Once is a shortcut for Times(1).
+func (e *Expectation) Once() *Expectation {
+	return e.Times(1)
+}
+
+// Calls returns how many requests matched the expectation.
+func (e *Expectation) Calls() int {
+	e.mock.mu.Lock()
+	defer e.mock.mu.Unlock()
+	return e.calls
+}
+
+// Reply adds a response with the status code. Responses are returned in order and the last one is repeated.
+func (e *Expectation) Reply(status int) *MockResponse {
+	r := &MockResponse{expectation: e, status: status, header: http.Header{}}
+	e.responses = append(e.responses, r)
+	return r
+}
+
+// ReplyError adds an error returned instead of a response.
+func (e *Expectation) ReplyError(err error) *MockResponse {
+	r := &MockResponse{expectation: e, err: err}
+	e.responses = append(e.responses, r)
+	return r
+}
+
+// Header sets a response header.
+func (r *MockResponse) Header(name, value string) *MockResponse {
+	r.header.Add(name, value)
+	return r
+}
+
+// Body sets the response body.
+func (r *MockResponse) Body(body string) *MockResponse {
+	r.body = []byte(body)
+	return r
+}
+
+// Json sets the response body to v encoded as JSON.
+func (r *MockResponse) Json(v interface{}) *MockResponse {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	r.body = b
+	r.header.Set("Content-Type", "application/json")
+	return r
+}
+
+// Then returns the expectation to add the next response of the sequence.
+func (r *MockResponse) Then() *Expectation {
+	return r.expectation
+}
+
+// Do responds to the request by the first expectation matching it, or returns an error if there is none.
+func (m *MockTransporter) Do(req *http.Request) (*http.Response, error) {
+	body, err := sugar.ReadRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var reasons []string
+	for _, e := range m.expectations {
+		mismatches := e.mismatches(req, body)
+		if len(mismatches) == 0 && e.times > 0 && e.calls >= e.times {
+			mismatches = append(mismatches, fmt.Sprintf("called %d times already", e.calls))
+		}
+		if len(mismatches) > 0 {
+			reasons = append(reasons, fmt.Sprintf("  %s %s:\n    %s", e.method, e.pattern, strings.Join(mismatches, "\n    ")))
+			continue
+		}
+
+		e.calls++
+		if len(e.responses) == 0 {
+			return response(req, &MockResponse{status: http.StatusOK, header: http.Header{}})
+		}
+		r := e.responses[len(e.responses)-1]
+		if e.calls <= len(e.responses) {
+			r = e.responses[e.calls-1]
+		}
+		return response(req, r)
+	}
+
+	report := fmt.Sprintf("unexpected request %s %s", req.Method, req.URL)
+	if len(body) > 0 {
+		report += "\n  body: " + string(body)
+	}
+	if len(reasons) > 0 {
+		report += "\n" + strings.Join(reasons, "\n")
+	}
+	m.unmatched = append(m.unmatched, report)
+	return nil, fmt.Errorf("sugartest: unexpected request %s %s", req.Method, req.URL)
+}
+
+// Verify reports unmatched requests and expectations which are not matched as many times as required.
+// It is called at t.Cleanup by NewMockTransporter.
+func (m *MockTransporter) Verify() {
+	m.t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, report := range m.unmatched {
+		m.t.Errorf("%s", report)
+	}
+	for _, e := range m.expectations {
+		switch {
+		case e.times > 0 && e.calls != e.times:
+			m.t.Errorf("expected %s %s to be called %d times, but it was called %d times", e.method, e.pattern, e.times, e.calls)
+		case e.times == 0 && e.calls == 0:
+			m.t.Errorf("expected %s %s to be called, but it was not called", e.method, e.pattern)
+		}
+	}
+}
+
+func (e *Expectation) mismatches(req *http.Request, body []byte) []string {
+	var mismatches []string
+	if !strings.EqualFold(e.method, req.Method) {
+		mismatches = append(mismatches, fmt.Sprintf("method: want %s, got %s", e.method, req.Method))
+	}
+	if !matchPattern(e.pattern, req) {
+		mismatches = append(mismatches, fmt.Sprintf("url: want %s, got %s", e.pattern, req.URL))
+	}
+
+	query := req.URL.Query()
+	for _, name := range sortedKeys(e.query) {
+		if values, ok := query[name]; !ok {
+			mismatches = append(mismatches, fmt.Sprintf("query %s: want %q, got none", name, e.query[name]))
+		} else if !contains(values, e.query[name]) {
+			mismatches = append(mismatches, fmt.Sprintf("query %s: want %q, got %q", name, e.query[name], strings.Join(values, ",")))
+		}
+	}
+
+	for _, name := range sortedKeys(e.header) {
+		if got := req.Header.Get(name); got != e.header[name] {
+			mismatches = append(mismatches, fmt.Sprintf("header %s: want %q, got %q", name, e.header[name], got))
+		}
+	}
+
+	if e.body != nil && *e.body != string(body) {
+		mismatches = append(mismatches, fmt.Sprintf("body: want %s, got %s", *e.body, body))
+	}
+	if e.hasJson {
+		want, _ := json.Marshal(e.jsonBody)
+		if !jsonEqual(want, body) {
+			mismatches = append(mismatches, fmt.Sprintf("json body: want %s, got %s", want, body))
+		}
+	}
+	return mismatches
+}
+
+func matchPattern(pattern string, req *http.Request) bool {
+	path := req.URL.Path
+	if i := strings.Index(pattern, "://"); i >= 0 {
+		origin := req.URL.Scheme + "://" + req.URL.Host
+		rest := pattern[i+3:]
+		host := rest
+		if j := strings.Index(rest, "/"); j >= 0 {
+			host, rest = rest[:j], rest[j:]
+		} else {
+			rest = "/"
+		}
+		if pattern[:i+3]+host != origin {
+			return false
+		}
+		pattern = rest
+	}
+	if i := strings.Index(pattern, "?"); i >= 0 {
+		pattern = pattern[:i]
+	}
+
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range patternSegments {
+		if segment == "*" && i == len(patternSegments)-1 {
+			return true
+		}
+		if i >= len(pathSegments) {
+			return false
+		}
+		if !strings.HasPrefix(segment, ":") && segment != pathSegments[i] {
+			return false
+		}
+	}
+	return len(patternSegments) == len(pathSegments)
+}
+
+func jsonEqual(a, b []byte) bool {
+	var x, y interface{}
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func response(req *http.Request, r *MockResponse) (*http.Response, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return sugar.NewResponse(req, r.status, r.header.Clone(), r.body), nil
+}
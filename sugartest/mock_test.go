@@ -0,0 +1,113 @@
+package sugartest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pojozhang/sugar"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeT records failures of a MockTransporter instead of failing the test.
+type fakeT struct {
+	testing.TB
+	errors   []string
+	cleanups []func()
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeT) Cleanup(f func()) {
+	t.cleanups = append(t.cleanups, f)
+}
+
+func (t *fakeT) finish() {
+	for _, f := range t.cleanups {
+		f()
+	}
+}
+
+func TestMockTransporter(t *testing.T) {
+	mock := NewMockTransporter(t)
+	books := mock.On(http.MethodGet, "/books/:id").
+		WithQuery("lang", "en").
+		WithHeader("Accept", "application/json").
+		Reply(http.StatusOK).Header("X-Version", "1").Json(map[string]string{"name": "bookA"}).
+		Then()
+	mock.On(http.MethodPost, "http://api.example.com/books").
+		WithJsonBody(map[string]interface{}{"name": "bookB"}).
+		Once().
+		Reply(http.StatusCreated)
+	client := sugar.New(func() sugar.Transporter { return mock })
+
+	var book map[string]string
+	resp, err := client.Get(context.Background(), "http://api.example.com/books/:id", sugar.Path{"id": 1}, sugar.Query{"lang": "en"}, sugar.Header{"Accept": "application/json"}).Read(&book)
+	assert.Nil(t, err)
+	assert.Equal(t, "1", resp.Header.Get("X-Version"))
+	assert.Equal(t, map[string]string{"name": "bookA"}, book)
+	assert.Equal(t, 1, books.Calls())
+
+	resp, err = client.Post(context.Background(), "http://api.example.com/books", sugar.Json{Payload: `{ "name" : "bookB" }`}).Raw()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+}
+
+func TestMockTransporterSequence(t *testing.T) {
+	mock := NewMockTransporter(t)
+	mock.On(http.MethodGet, "/books/*").
+		Times(3).
+		ReplyError(&net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset")}).
+		Then().Reply(http.StatusServiceUnavailable).
+		Then().Reply(http.StatusOK).Body("ok")
+	client := sugar.New(func() sugar.Transporter { return mock })
+	client.Use(sugar.Retryer(3, time.Millisecond, 1, time.Millisecond))
+
+	b, resp, err := client.Get(context.Background(), "http://api.example.com/books/1/pages").ReadBytes()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "ok", string(b))
+}
+
+func TestMockTransporterReportsUnmatchedRequests(t *testing.T) {
+	ft := &fakeT{TB: t}
+	mock := NewMockTransporter(ft)
+	mock.On(http.MethodGet, "/books/:id").WithQuery("lang", "en").WithHeader("Accept", "application/json").Reply(http.StatusOK)
+	mock.On(http.MethodDelete, "/books/:id").Once()
+
+	req, _ := http.NewRequest(http.MethodGet, "http://api.example.com/books/1?lang=fr", nil)
+	_, err := mock.Do(req)
+	assert.NotNil(t, err)
+	req, _ = http.NewRequest(http.MethodDelete, "http://api.example.com/books/1", nil)
+	for i := 0; i < 2; i++ {
+		mock.Do(req)
+	}
+
+	ft.finish()
+	assert.Len(t, ft.errors, 3)
+	assert.True(t, strings.HasPrefix(ft.errors[0], "unexpected request GET http://api.example.com/books/1?lang=fr"))
+	assert.Contains(t, ft.errors[0], `query lang: want "en", got "fr"`)
+	assert.Contains(t, ft.errors[0], `header Accept: want "application/json", got ""`)
+	assert.Contains(t, ft.errors[0], "method: want DELETE, got GET")
+	assert.Contains(t, ft.errors[1], "called 1 times already")
+	assert.Equal(t, "expected GET /books/:id to be called, but it was not called", ft.errors[2])
+}
+
+func TestMatchPattern(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://api.example.com/books/1", nil)
+	assert.True(t, matchPattern("/books/:id", req))
+	assert.True(t, matchPattern("https://api.example.com/books/:id", req))
+	assert.True(t, matchPattern("/books/*", req))
+	assert.False(t, matchPattern("http://api.example.com/books/:id", req))
+	assert.False(t, matchPattern("/books", req))
+	assert.False(t, matchPattern("/books/:id/pages", req))
+}
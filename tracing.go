@@ -0,0 +1,238 @@
+package sugar
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID identifies a span.
+type SpanID [8]byte
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext is the part of a span which is propagated to the server.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool
+	TraceState string
+}
+
+// Span records a client request.
+type Span interface {
+	SpanContext() SpanContext
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// Tracer starts spans. It is a small interface so that it can be adapted to OpenTelemetry.
+type Tracer interface {
+	// Start starts a span as a child of the span in ctx and returns a context containing the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Tracing is a plugin which creates a client span for every request and propagates it via
+// W3C Trace Context headers, and B3 headers optionally.
+type Tracing struct {
+	// Tracer starts spans. If it is nil, spans are not recorded and only the span context of the request context is propagated.
+	Tracer Tracer
+	// B3 also injects X-B3-TraceId, X-B3-SpanId and X-B3-Sampled headers.
+	B3 bool
+	// SpanName names a span, default "HTTP <method> <url template>".
+	SpanName func(c *Context) string
+}
+
+// Handle starts a span, injects headers and records attributes of the exchange.
+func (t *Tracing) Handle(c *Context) error {
+	route := routeTemplate(c)
+	name := "HTTP " + c.Request.Method + " " + route
+	if t.SpanName != nil {
+		name = t.SpanName(c)
+	}
+
+	var tracer Tracer = noopTracer{}
+	if t.Tracer != nil {
+		tracer = t.Tracer
+	}
+	ctx, span := tracer.Start(c.Request.Context(), name)
+	defer span.End()
+
+	req := c.Request.WithContext(ctx)
+	req.Header = req.Header.Clone()
+	sc := span.SpanContext()
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	// An all-zero trace id is invalid, so nothing is injected without a trace.
+	if sc.TraceID != (TraceID{}) {
+		req.Header.Set("traceparent", "00-"+sc.TraceID.String()+"-"+sc.SpanID.String()+"-"+flags)
+		if sc.TraceState != "" {
+			req.Header.Set("tracestate", sc.TraceState)
+		}
+		if t.B3 {
+			req.Header.Set("X-B3-TraceId", sc.TraceID.String())
+			req.Header.Set("X-B3-SpanId", sc.SpanID.String())
+			req.Header.Set("X-B3-Sampled", flags[1:])
+		}
+	}
+	c.Request = req
+
+	span.SetAttribute("http.request.method", req.Method)
+	span.SetAttribute("url.full", redactedUrl(req.URL))
+	span.SetAttribute("http.route", route)
+	span.SetAttribute("server.address", req.URL.Hostname())
+
+	err := c.Next()
+	if c.Response != nil {
+		span.SetAttribute("http.response.status_code", c.Response.StatusCode)
+		if c.Response.StatusCode >= 400 {
+			span.SetAttribute("error.type", strconv.Itoa(c.Response.StatusCode))
+		}
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+// routeTemplate returns the path of the url before path params are encoded, e.g. /books/:id.
+func routeTemplate(c *Context) string {
+	if c.RawUrl != "" {
+		if u, err := url.Parse(c.RawUrl); err == nil {
+			return u.Path
+		}
+	}
+	return c.Request.URL.Path
+}
+
+func redactedUrl(u *url.URL) string {
+	if u.User == nil {
+		return u.String()
+	}
+	redacted := *u
+	redacted.User = url.User("REDACTED")
+	return redacted.String()
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a context which carries the span context as the parent of following spans.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context carried by ctx.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok
+}
+
+// noopTracer is used if Tracer is nil. Its spans record nothing and carry the span context of ctx.
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	sc, _ := SpanContextFromContext(ctx)
+	return ctx, noopSpan{sc: sc}
+}
+
+type noopSpan struct {
+	sc SpanContext
+}
+
+func (s noopSpan) SpanContext() SpanContext {
+	return s.sc
+}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+
+func (noopSpan) RecordError(err error) {}
+
+func (noopSpan) End() {}
+
+// RecordingTracer is a Tracer which keeps ended spans in memory, mainly for tests and local debugging.
+type RecordingTracer struct {
+	mu    sync.Mutex
+	spans []*RecordedSpan
+}
+
+// RecordedSpan is a span created by RecordingTracer.
+type RecordedSpan struct {
+	Name       string
+	Context    SpanContext
+	Parent     SpanContext
+	Attributes map[string]interface{}
+	Errors     []error
+	StartTime  time.Time
+	EndTime    time.Time
+
+	tracer *RecordingTracer
+	mu     sync.Mutex
+}
+
+// Start starts a sampled span whose parent is the span context in ctx.
+func (t *RecordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, ok := SpanContextFromContext(ctx)
+	sc := SpanContext{Sampled: true}
+	if ok {
+		sc.TraceID, sc.TraceState = parent.TraceID, parent.TraceState
+	} else {
+		rand.Read(sc.TraceID[:])
+	}
+	rand.Read(sc.SpanID[:])
+
+	span := &RecordedSpan{Name: name, Context: sc, Parent: parent, Attributes: map[string]interface{}{}, StartTime: time.Now(), tracer: t}
+	return ContextWithSpanContext(ctx, sc), span
+}
+
+// Spans returns ended spans.
+func (t *RecordingTracer) Spans() []*RecordedSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*RecordedSpan(nil), t.spans...)
+}
+
+// SpanContext returns the span context.
+func (s *RecordedSpan) SpanContext() SpanContext {
+	return s.Context
+}
+
+// SetAttribute sets an attribute.
+func (s *RecordedSpan) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Attributes[key] = value
+}
+
+// RecordError records an error.
+func (s *RecordedSpan) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Errors = append(s.Errors, err)
+}
+
+// End ends the span and hands it to the tracer.
+func (s *RecordedSpan) End() {
+	s.mu.Lock()
+	s.EndTime = time.Now()
+	s.mu.Unlock()
+
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.tracer.spans = append(s.tracer.spans, s)
+}
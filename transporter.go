@@ -1,9 +1,234 @@
 package sugar
 
-import "net/http"
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
 
 // Transporter is an important interface for different http clients.
 type Transporter interface {
 	// Do issues the request.
 	Do(req *http.Request) (*http.Response, error)
 }
+
+// HandlerTransporter returns a Transporter which serves requests by invoking the handler in-process without sockets.
+// The response is returned as soon as the handler writes the header, so that streaming bodies can be read while
+// the handler is still running. Trailers are available after the body is read to the end.
+func HandlerTransporter(handler http.Handler) Transporter {
+	return &handlerTransporter{handler: handler}
+}
+
+type handlerTransporter struct {
+	handler http.Handler
+}
+
+// Do invokes the handler with a server-side copy of the request.
+func (t *handlerTransporter) Do(req *http.Request) (*http.Response, error) {
+	body := req.Body
+	if req.GetBody != nil {
+		var err error
+		body, err = req.GetBody()
+		// The original body is closed as http.Transport does, because the handler reads the copy instead.
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if body == nil {
+		body = http.NoBody
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	sreq := req.Clone(ctx)
+	sreq.Body = body
+	sreq.URL = &url.URL{Path: req.URL.Path, RawPath: req.URL.RawPath, RawQuery: req.URL.RawQuery}
+	sreq.RequestURI = req.URL.RequestURI()
+	sreq.Proto, sreq.ProtoMajor, sreq.ProtoMinor = "HTTP/1.1", 1, 1
+	sreq.RemoteAddr = "192.0.2.1:1234"
+	if sreq.Host == "" {
+		sreq.Host = req.URL.Host
+	}
+	if u := req.URL.User; u != nil && sreq.Header.Get("Authorization") == "" {
+		password, _ := u.Password()
+		sreq.SetBasicAuth(u.Username(), password)
+	}
+
+	pr, pw := io.Pipe()
+	w := &handlerResponseWriter{
+		header: http.Header{},
+		pw:     pw,
+		head:   req.Method == http.MethodHead,
+		ready:  make(chan struct{}),
+		resp: &http.Response{
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Body:       &handlerBody{PipeReader: pr, cancel: cancel},
+			Request:    req,
+		},
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer cancel()
+		defer func() {
+			if p := recover(); p != nil {
+				w.fail(fmt.Errorf("handler panic: %v", p))
+			}
+		}()
+		t.handler.ServeHTTP(w, sreq)
+		if err := req.Context().Err(); err != nil {
+			w.fail(err)
+			return
+		}
+		w.finish()
+	}()
+
+	// A canceled request interrupts reading the body, as a closed connection does.
+	go func() {
+		select {
+		case <-req.Context().Done():
+			pw.CloseWithError(req.Context().Err())
+		case <-done:
+		}
+	}()
+
+	select {
+	case <-w.ready:
+	case <-req.Context().Done():
+		cancel()
+		return nil, req.Context().Err()
+	}
+	if w.err != nil {
+		return nil, w.err
+	}
+	return w.resp, nil
+}
+
+// handlerBody cancels the context of the server-side request when the client closes the body.
+type handlerBody struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+func (b *handlerBody) Close() error {
+	b.cancel()
+	return b.PipeReader.Close()
+}
+
+type handlerResponseWriter struct {
+	mu          sync.Mutex
+	header      http.Header
+	resp        *http.Response
+	pw          *io.PipeWriter
+	head        bool
+	wroteHeader bool
+	ready       chan struct{}
+	err         error
+}
+
+func (w *handlerResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *handlerResponseWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writeHeader(code)
+}
+
+// writeHeader sends a snapshot of the header to the client. It must be called with w.mu held.
+func (w *handlerResponseWriter) writeHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	header := w.header.Clone()
+	for _, name := range header.Values("Trailer") {
+		for _, key := range strings.Split(name, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				if w.resp.Trailer == nil {
+					w.resp.Trailer = http.Header{}
+				}
+				w.resp.Trailer[http.CanonicalHeaderKey(key)] = nil
+			}
+		}
+	}
+	header.Del("Trailer")
+	for key := range header {
+		if strings.HasPrefix(key, http.TrailerPrefix) {
+			delete(header, key)
+		}
+	}
+
+	w.resp.StatusCode = code
+	w.resp.Status = strconv.Itoa(code) + " " + http.StatusText(code)
+	w.resp.Header = header
+	w.resp.ContentLength = -1
+	if n, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil {
+		w.resp.ContentLength = n
+	}
+	close(w.ready)
+}
+
+func (w *handlerResponseWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	if !w.wroteHeader {
+		if w.header.Get(ContentType) == "" && len(p) > 0 {
+			w.header.Set(ContentType, http.DetectContentType(p))
+		}
+		w.writeHeader(http.StatusOK)
+	}
+	w.mu.Unlock()
+
+	if w.head {
+		return len(p), nil
+	}
+	return w.pw.Write(p)
+}
+
+// Flush sends the header if it has not been sent. Writes are not buffered.
+func (w *handlerResponseWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+}
+
+// finish sets trailers and ends the body after the handler returns.
+func (w *handlerResponseWriter) finish() {
+	w.mu.Lock()
+	w.writeHeader(http.StatusOK)
+	for key := range w.resp.Trailer {
+		w.resp.Trailer[key] = w.header.Values(key)
+	}
+	for key, values := range w.header {
+		if strings.HasPrefix(key, http.TrailerPrefix) {
+			if w.resp.Trailer == nil {
+				w.resp.Trailer = http.Header{}
+			}
+			w.resp.Trailer[http.CanonicalHeaderKey(strings.TrimPrefix(key, http.TrailerPrefix))] = values
+		}
+	}
+	w.mu.Unlock()
+	w.pw.Close()
+}
+
+// fail ends the body with err, or fails the request if the header has not been sent.
+func (w *handlerResponseWriter) fail(err error) {
+	w.mu.Lock()
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.err = err
+		close(w.ready)
+	}
+	w.mu.Unlock()
+	w.pw.CloseWithError(err)
+}
@@ -0,0 +1,137 @@
+package sugar
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandlerTransporter(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/books/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/books/1?lang=en", r.RequestURI)
+		assert.Equal(t, "api.example.com", r.Host)
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set(ContentType, "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"name":` + string(b) + `}`))
+	})
+	client := New(func() Transporter { return HandlerTransporter(mux) })
+
+	var book map[string]string
+	resp, err := client.Post(context.Background(), "http://api.example.com/books/:id", Path{"id": 1}, Query{"lang": "en"}, `"bookA"`).Read(&book)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, map[string]string{"name": "bookA"}, book)
+}
+
+func TestHandlerTransporterStreamsBodyAndTrailers(t *testing.T) {
+	next := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		for i := 0; i < 2; i++ {
+			w.Write([]byte("line\n"))
+			w.(http.Flusher).Flush()
+			<-next
+		}
+		w.Header().Set("X-Checksum", "abc")
+		w.Header().Set(http.TrailerPrefix+"X-Count", "2")
+	})
+
+	resp, err := HandlerTransporter(handler).Do(mustRequest(http.MethodGet, "http://api.example.com/events", nil))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int64(-1), resp.ContentLength)
+	assert.Empty(t, resp.Header.Get("Trailer"))
+
+	reader := bufio.NewReader(resp.Body)
+	for i := 0; i < 2; i++ {
+		line, err := reader.ReadString('\n')
+		assert.Nil(t, err)
+		assert.Equal(t, "line\n", line)
+		next <- struct{}{}
+	}
+	_, err = reader.ReadByte()
+	assert.Equal(t, "EOF", err.Error())
+	assert.Equal(t, "abc", resp.Trailer.Get("X-Checksum"))
+	assert.Equal(t, "2", resp.Trailer.Get("X-Count"))
+}
+
+func TestHandlerTransporterCancellation(t *testing.T) {
+	canceled := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		<-r.Context().Done()
+		close(canceled)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://api.example.com/events", nil)
+	resp, err := HandlerTransporter(handler).Do(req)
+	assert.Nil(t, err)
+
+	cancel()
+	_, err = ioutil.ReadAll(resp.Body)
+	assert.True(t, errors.Is(err, context.Canceled), "%v", err)
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("handler context is not canceled")
+	}
+}
+
+func TestHandlerTransporterCancellationBeforeHeader(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://api.example.com/slow", nil)
+	_, err := HandlerTransporter(handler).Do(req)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestHandlerTransporterUsesGetBody(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write(b)
+	})
+	req := mustRequest(http.MethodPost, "http://api.example.com/books", nil)
+	setBody(req, []byte("bookA"))
+	body := &closeRecordingBody{Reader: strings.NewReader("bookA")}
+	req.Body = body
+	transporter := HandlerTransporter(handler)
+
+	for i := 0; i < 2; i++ {
+		resp, err := transporter.Do(req)
+		assert.Nil(t, err)
+		b, _ := ioutil.ReadAll(resp.Body)
+		assert.Equal(t, "bookA", string(b))
+	}
+	assert.True(t, body.closed)
+}
+
+func TestHandlerTransporterPanic(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	_, err := HandlerTransporter(handler).Do(mustRequest(http.MethodGet, "http://api.example.com", nil))
+	assert.True(t, strings.Contains(err.Error(), "boom"))
+}
+
+func mustRequest(method, url string, body []byte) *http.Request {
+	req, _ := http.NewRequest(method, url, nil)
+	if body != nil {
+		setBody(req, body)
+	}
+	return req
+}
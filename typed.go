@@ -0,0 +1,65 @@
+package sugar
+
+import (
+	"context"
+	"net/http"
+)
+
+// Result carries a decoded value of type T along with the status and headers of the response.
+type Result[T any] struct {
+	StatusCode int
+	Header     http.Header
+	Value      T
+	Response   *http.Response
+}
+
+// ReadAs decodes a response into a new value of type T via decoders.
+func ReadAs[T any](r *Response) (*Result[T], error) {
+	var v T
+	resp, err := r.Read(&v)
+	if resp == nil {
+		return nil, err
+	}
+
+	return &Result[T]{StatusCode: resp.StatusCode, Header: resp.Header, Value: v, Response: resp}, err
+}
+
+// DoAs sends a request via the client and decodes the response into a value of type T.
+// The default client is used if client is nil.
+func DoAs[T any](ctx context.Context, client *Client, method, rawUrl string, params ...interface{}) (T, *http.Response, error) {
+	if client == nil {
+		client = defaultClient
+	}
+
+	result, err := ReadAs[T](client.Do(ctx, method, rawUrl, params...))
+	if result == nil {
+		var v T
+		return v, nil, err
+	}
+	return result.Value, result.Response, err
+}
+
+// GetAs is a shortcut for DoAs[T](ctx, client, "Get", url, params).
+func GetAs[T any](ctx context.Context, client *Client, rawUrl string, params ...interface{}) (T, *http.Response, error) {
+	return DoAs[T](ctx, client, http.MethodGet, rawUrl, params...)
+}
+
+// PostAs is a shortcut for DoAs[T](ctx, client, "Post", url, params).
+func PostAs[T any](ctx context.Context, client *Client, rawUrl string, params ...interface{}) (T, *http.Response, error) {
+	return DoAs[T](ctx, client, http.MethodPost, rawUrl, params...)
+}
+
+// PutAs is a shortcut for DoAs[T](ctx, client, "Put", url, params).
+func PutAs[T any](ctx context.Context, client *Client, rawUrl string, params ...interface{}) (T, *http.Response, error) {
+	return DoAs[T](ctx, client, http.MethodPut, rawUrl, params...)
+}
+
+// PatchAs is a shortcut for DoAs[T](ctx, client, "Patch", url, params).
+func PatchAs[T any](ctx context.Context, client *Client, rawUrl string, params ...interface{}) (T, *http.Response, error) {
+	return DoAs[T](ctx, client, http.MethodPatch, rawUrl, params...)
+}
+
+// DeleteAs is a shortcut for DoAs[T](ctx, client, "Delete", url, params).
+func DeleteAs[T any](ctx context.Context, client *Client, rawUrl string, params ...interface{}) (T, *http.Response, error) {
+	return DoAs[T](ctx, client, http.MethodDelete, rawUrl, params...)
+}
@@ -0,0 +1,48 @@
+package sugar
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestGetAs(t *testing.T) {
+	defer gock.Off()
+	gock.New("http://api.example.com").
+		Get("/books").
+		Reply(http.StatusOK).
+		JSON(`[{"name":"bookA"},{"name":"bookB"}]`)
+
+	books, resp, err := GetAs[[]book](context.Background(), nil, "http://api.example.com/books")
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "bookB", books[1].Name)
+}
+
+func TestPostAs_Returns_Error_When_Request_Fails(t *testing.T) {
+	b, resp, err := PostAs[book](context.Background(), New(StandardClient), "http://api.example.com/books", make(chan int))
+
+	assert.NotNil(t, err)
+	assert.Nil(t, resp)
+	assert.Equal(t, book{}, b)
+}
+
+func TestReadAs(t *testing.T) {
+	defer gock.Off()
+	gock.New("http://api.example.com").
+		Get("/books/1").
+		Reply(http.StatusOK).
+		SetHeader("X-Trace", "trace").
+		JSON(`{"name":"bookA"}`)
+
+	result, err := ReadAs[book](Get(context.Background(), "http://api.example.com/books/1"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "trace", result.Header.Get("X-Trace"))
+	assert.Equal(t, "bookA", result.Value.Name)
+}